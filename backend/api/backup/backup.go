@@ -0,0 +1,513 @@
+// Package backup 对 SQLite 数据库与数据目录中的本地文件做一致性快照、校验、轮换与恢复。
+//
+// 快照目录结构：
+//
+//	<backup.dir>/<name>/
+//	    manifest.json      元数据与每个文件的 SHA-256
+//	    siyuan-share.db    VACUUM INTO 生成的数据库副本
+//	    files/...          数据目录中的其它本地文件（资源等）
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	KindManual    = "manual"
+	KindScheduled = "scheduled"
+
+	manifestFile = "manifest.json"
+	dbFile       = "siyuan-share.db"
+	filesDir     = "files"
+	tmpPrefix    = ".tmp-"
+)
+
+var (
+	// ErrUnsupported 非 SQLite 数据库不支持快照
+	ErrUnsupported = errors.New("snapshots are only supported for SQLite; use your database's native backup tooling")
+	// ErrNotFound 快照不存在
+	ErrNotFound = errors.New("snapshot not found")
+
+	namePattern = regexp.MustCompile(`^\d{8}T\d{6}Z-(manual|scheduled)$`)
+
+	// mu 串行化快照创建与轮换（手动接口与定时任务共用）
+	mu sync.Mutex
+)
+
+// FileEntry 快照中的文件及校验和
+type FileEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest 快照元数据
+type Manifest struct {
+	Name          string      `json:"name"`
+	Kind          string      `json:"kind"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	SchemaVersion int         `json:"schemaVersion"`
+	Size          int64       `json:"size"`
+	Files         []FileEntry `json:"files"`
+}
+
+// Manager 快照管理
+type Manager struct {
+	DB      *gorm.DB // 在线快照使用的连接；仅恢复时可为空
+	DBPath  string   // SQLite 数据库文件
+	DataDir string
+	Dir     string // 快照目录
+}
+
+// New 根据配置创建快照管理器；数据库不是 SQLite 时返回 ErrUnsupported
+func New(cfg *config.Config, db *gorm.DB) (*Manager, error) {
+	target, err := config.ParseDatabaseURL(cfg.Database.URL, cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if target.Driver != config.DriverSQLite {
+		return nil, ErrUnsupported
+	}
+	return &Manager{DB: db, DBPath: target.DSN, DataDir: cfg.DataDir, Dir: cfg.BackupDir()}, nil
+}
+
+// Create 生成一致性快照：数据库通过 VACUUM INTO 在线复制（不阻塞写入），随后复制数据目录中的本地文件
+func (m *Manager) Create(kind string) (*Manifest, error) {
+	if m.DB == nil {
+		return nil, errors.New("backup: database is not open")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	name := now.Format("20060102T150405Z") + "-" + kind
+	if _, err := os.Stat(filepath.Join(m.Dir, name)); err == nil {
+		return nil, fmt.Errorf("backup: snapshot %s already exists, retry in a second", name)
+	}
+	tmp := filepath.Join(m.Dir, tmpPrefix+name)
+	if err := os.MkdirAll(tmp, 0700); err != nil {
+		return nil, err
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			os.RemoveAll(tmp)
+		}
+	}()
+
+	if err := m.DB.Exec("VACUUM INTO ?", filepath.Join(tmp, dbFile)).Error; err != nil {
+		return nil, fmt.Errorf("backup: vacuum into: %w", err)
+	}
+	man := &Manifest{Name: name, Kind: kind, CreatedAt: now}
+	if st, err := models.GetMigrationStatus(m.DB); err == nil {
+		man.SchemaVersion = st.Current
+	}
+	entry, err := checksumFile(filepath.Join(tmp, dbFile), dbFile)
+	if err != nil {
+		return nil, err
+	}
+	man.Files = append(man.Files, entry)
+
+	assets, err := m.assetFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range assets {
+		dst := filepath.Join(filesDir, rel)
+		entry, err := copyFile(filepath.Join(m.DataDir, rel), filepath.Join(tmp, dst))
+		if err != nil {
+			return nil, err
+		}
+		entry.Path = filepath.ToSlash(dst)
+		man.Files = append(man.Files, entry)
+	}
+	for _, f := range man.Files {
+		man.Size += f.Size
+	}
+	if err := writeManifest(tmp, man); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, filepath.Join(m.Dir, name)); err != nil {
+		return nil, err
+	}
+	ok = true
+	slog.Info("Backup snapshot created", "name", name, "files", len(man.Files), "bytes", man.Size)
+	return man, nil
+}
+
+// List 按时间倒序列出快照（未完成的临时目录与无法读取的目录会被忽略）
+func (m *Manager) List() ([]Manifest, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Manifest
+	for _, e := range entries {
+		if !e.IsDir() || !namePattern.MatchString(e.Name()) {
+			continue
+		}
+		man, err := readManifest(filepath.Join(m.Dir, e.Name()))
+		if err != nil {
+			slog.Warn("Backup: skipping unreadable snapshot", "name", e.Name(), "error", err)
+			continue
+		}
+		man.Name = e.Name() // 以目录名为准，避免清单被改动后删错目录
+		list = append(list, *man)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// Path 返回快照目录；name 必须是本管理器生成的快照名，防止路径穿越
+func (m *Manager) Path(name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", ErrNotFound
+	}
+	dir := filepath.Join(m.Dir, name)
+	if _, err := os.Stat(dir); err != nil {
+		return "", ErrNotFound
+	}
+	return dir, nil
+}
+
+// Verify 重新计算快照中每个文件的校验和并与清单比对
+func Verify(dir string) (*Manifest, error) {
+	man, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	hasDB := false
+	for _, f := range man.Files {
+		if f.Path == dbFile {
+			hasDB = true
+		}
+		p := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if !strings.HasPrefix(p, filepath.Clean(dir)+string(filepath.Separator)) {
+			return nil, fmt.Errorf("backup: invalid path in manifest: %s", f.Path)
+		}
+		if strings.HasPrefix(f.Path, filesDir+"/") {
+			if _, err := dataFilePath(filepath.Join(dir, filesDir), f.Path); err != nil {
+				return nil, err
+			}
+		}
+		got, err := checksumFile(p, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if got.Size != f.Size || got.SHA256 != f.SHA256 {
+			return nil, fmt.Errorf("backup: checksum mismatch for %s", f.Path)
+		}
+	}
+	if !hasDB {
+		return nil, fmt.Errorf("backup: manifest does not contain %s", dbFile)
+	}
+	return man, nil
+}
+
+// Rotate 轮换定时快照：保留最近 keepDaily 天每天最新的一份，另保留最近 keepWeekly 周每周最新的一份。
+// 手动快照不参与轮换。返回被删除的快照名。
+func (m *Manager) Rotate(keepDaily, keepWeekly int) ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	var removed []string
+	for _, man := range list { // 已按时间倒序
+		if man.Kind != KindScheduled {
+			continue
+		}
+		t := man.CreatedAt.Local()
+		keep := false
+		if day := t.Format("2006-01-02"); !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep = true
+		}
+		y, w := t.ISOWeek()
+		if week := fmt.Sprintf("%d-W%02d", y, w); !weeks[week] && len(weeks) < keepWeekly {
+			weeks[week] = true
+			keep = true
+		}
+		if keep {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(m.Dir, man.Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, man.Name)
+	}
+	if len(removed) > 0 {
+		slog.Info("Backup rotation", "removed", len(removed))
+	}
+	return removed, nil
+}
+
+// RunScheduler 按间隔生成定时快照并轮换，直到 ctx 结束。启动时若距上次定时快照已超过间隔则立即执行一次。
+func (m *Manager) RunScheduler(ctx context.Context, interval time.Duration, keepDaily, keepWeekly int) {
+	slog.Info("Backup scheduler started", "interval", interval.String(), "keep_daily", keepDaily, "keep_weekly", keepWeekly)
+	for {
+		var wait time.Duration
+		if last := m.lastScheduled(); !last.IsZero() {
+			wait = time.Until(last.Add(interval))
+		}
+		if wait < 0 {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if _, err := m.Create(KindScheduled); err != nil {
+			slog.Error("Scheduled backup failed", "error", err)
+			// 失败后至少间隔一分钟再重试，避免磁盘满等情况下空转
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+			}
+			continue
+		}
+		if _, err := m.Rotate(keepDaily, keepWeekly); err != nil {
+			slog.Error("Backup rotation failed", "error", err)
+		}
+	}
+}
+
+func (m *Manager) lastScheduled() time.Time {
+	list, err := m.List()
+	if err != nil {
+		return time.Time{}
+	}
+	for _, man := range list {
+		if man.Kind == KindScheduled {
+			return man.CreatedAt
+		}
+	}
+	return time.Time{}
+}
+
+// Restore 校验快照后替换当前数据库与本地文件。必须在服务停止时执行。
+// 原数据库文件重命名为 <db>.pre-restore-<时间> 保留，恢复失败时可手动还原。
+func (m *Manager) Restore(dir string) (*Manifest, error) {
+	man, err := Verify(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先复制到目标目录旁的临时文件并校验，确保替换是同一文件系统内的原子重命名
+	if err := os.MkdirAll(filepath.Dir(m.DBPath), 0755); err != nil {
+		return nil, err
+	}
+	staged := m.DBPath + ".restore-tmp"
+	os.Remove(staged)
+	if _, err := copyFile(filepath.Join(dir, dbFile), staged); err != nil {
+		return nil, err
+	}
+	defer os.Remove(staged)
+	if err := ValidateDatabase(staged); err != nil {
+		return nil, err
+	}
+
+	suffix := ".pre-restore-" + time.Now().UTC().Format("20060102T150405Z")
+	for _, ext := range []string{"", "-wal", "-shm"} {
+		p := m.DBPath + ext
+		if _, err := os.Stat(p); err == nil {
+			if err := os.Rename(p, p+suffix); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := os.Rename(staged, m.DBPath); err != nil {
+		return nil, err
+	}
+
+	for _, f := range man.Files {
+		if !strings.HasPrefix(f.Path, filesDir+"/") {
+			continue
+		}
+		dst, err := dataFilePath(m.DataDir, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := copyFile(filepath.Join(dir, filepath.FromSlash(f.Path)), dst); err != nil {
+			return nil, err
+		}
+	}
+	slog.Info("Restored snapshot", "name", man.Name, "previous", filepath.Base(m.DBPath+suffix))
+	return man, nil
+}
+
+// dataFilePath 清单中 files/ 条目在数据目录中的恢复位置，清理后不在数据目录内（如 files/../x）时返回错误
+func dataFilePath(dataDir, entry string) (string, error) {
+	p := filepath.Join(dataDir, filepath.FromSlash(strings.TrimPrefix(entry, filesDir+"/")))
+	if rel, err := filepath.Rel(filepath.Clean(dataDir), p); err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("backup: invalid path in manifest: %s", entry)
+	}
+	return p, nil
+}
+
+// ValidateDatabase 检查快照数据库完整性与表结构版本：版本必须有效且不高于本程序支持的版本
+func ValidateDatabase(path string) error {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return fmt.Errorf("backup: integrity check: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup: integrity check failed: %s", result)
+	}
+	if !db.Migrator().HasTable(&models.SchemaMigration{}) {
+		return errors.New("backup: snapshot has no schema_migrations table")
+	}
+	st, err := models.GetMigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	if st.Current == 0 {
+		return errors.New("backup: snapshot has no applied migrations")
+	}
+	if err := models.CheckSchemaVersion(st); err != nil {
+		return err
+	}
+	for _, model := range models.AllModels() {
+		if !db.Migrator().HasTable(model) {
+			return fmt.Errorf("backup: snapshot is missing table for %T", model)
+		}
+	}
+	return nil
+}
+
+// assetFiles 数据目录中需要随快照保存的本地文件（排除数据库文件、快照目录与恢复遗留文件）
+func (m *Manager) assetFiles() ([]string, error) {
+	root, err := filepath.Abs(m.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	backupDir, _ := filepath.Abs(m.Dir)
+	dbPath, _ := filepath.Abs(m.DBPath)
+
+	var files []string
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p == backupDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(p, dbPath) {
+			return nil // 数据库及其 -wal/-shm/-journal、.pre-restore-* 等
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return files, err
+}
+
+func writeManifest(dir string, man *Manifest) error {
+	data, err := json.MarshalIndent(man, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), data, 0600)
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var man Manifest
+	if err := json.Unmarshal(data, &man); err != nil {
+		return nil, fmt.Errorf("backup: invalid manifest: %w", err)
+	}
+	return &man, nil
+}
+
+// copyFile 复制文件并同时计算校验和
+func copyFile(src, dst string) (FileEntry, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return FileEntry{}, err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return FileEntry{}, err
+	}
+	return FileEntry{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func checksumFile(path, name string) (FileEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	return FileEntry{Path: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
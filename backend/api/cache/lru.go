@@ -0,0 +1,84 @@
+// Package cache 提供并发安全的定长 LRU 缓存。
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU 容量固定的最近最少使用缓存；容量不大于 0 时不缓存任何条目
+type LRU[K comparable, V any] struct {
+	mu      sync.Mutex
+	cap     int
+	ll      *list.List
+	entries map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewLRU 创建容量为 capacity 的缓存
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{cap: capacity, ll: list.New(), entries: make(map[K]*list.Element)}
+}
+
+// Get 读取并标记为最近使用
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*entry[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Add 写入或覆盖，超出容量时淘汰最久未使用的条目
+func (c *LRU[K, V]) Add(key K, value V) {
+	if c.cap <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*entry[K, V]).value = value
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value})
+	for c.ll.Len() > c.cap {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[K, V]).key)
+	}
+}
+
+// Remove 删除条目
+func (c *LRU[K, V]) Remove(keys ...K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.ll.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Purge 清空缓存
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.entries = make(map[K]*list.Element)
+}
+
+// Len 当前条目数
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
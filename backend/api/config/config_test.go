@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestValidateOriginPattern(t *testing.T) {
+	tests := []struct {
+		origin string
+		valid  bool
+	}{
+		{"*", true},
+		{"https://app.example.com", true},
+		{"http://localhost:*", true},
+		{"https://*.example.com", true},
+		{"https://*.example.com:*", true},
+		{"app.example.com", false},
+		{"https://", false},
+		{"https://app.example.com/", false},
+		{"https://app.example.com/path", false},
+		{"https://user@app.example.com", false},
+		{"https://app.example.com?x=1", false},
+		{"https://*", false},
+		{"https://*.*.example.com", false},
+		{"https://app.*.com", false},
+		{"https://app*.example.com", false},
+		{"*://app.example.com", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateOriginPattern(tt.origin); (err == nil) != tt.valid {
+			t.Errorf("ValidateOriginPattern(%q) = %v, want valid=%v", tt.origin, err, tt.valid)
+		}
+	}
+}
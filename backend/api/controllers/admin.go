@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SecuritySettingsRequest 管理员安全策略设置
+type SecuritySettingsRequest struct {
+	Require2FA *bool `json:"require2FA"`
+}
+
+// GetSecuritySettings 获取安全策略
+func GetSecuritySettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"require2FA": models.GetBoolSetting(models.SettingRequire2FA, false),
+	}})
+}
+
+// UpdateSecuritySettings 更新安全策略
+func UpdateSecuritySettings(c *gin.Context) {
+	var req SecuritySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Require2FA != nil {
+		enabling := *req.Require2FA && !models.GetBoolSetting(models.SettingRequire2FA, false)
+		if err := models.SetSetting(models.SettingRequire2FA, strconv.FormatBool(*req.Require2FA)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to save settings: " + err.Error()})
+			return
+		}
+		// 开启强制 2FA 时，未绑定用户的现有会话失效，重新登录时须先完成绑定
+		if enabling {
+			if err := reqDB(c).Model(&models.User{}).Where("totp_enabled = ?", false).
+				Update("session_version", gorm.Expr("session_version + 1")).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to revoke sessions: " + err.Error()})
+				return
+			}
+		}
+	}
+	GetSecuritySettings(c)
+}
+
+// ResetUserTOTP 管理员重置指定用户的双因素认证（用于设备丢失且恢复码用尽的情况）
+func ResetUserTOTP(c *gin.Context) {
+	id := c.Param("id")
+	var count int64
+	if err := reqDB(c).Model(&models.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query user: " + err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "User not found"})
+		return
+	}
+	if err := resetTOTP(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to reset two-factor authentication: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
@@ -4,13 +4,15 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
-	"os"
 	"time"
 
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
 	"github.com/ZeroHawkeye/siyuan-share-api/models"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type RegisterRequest struct {
@@ -29,7 +31,7 @@ func Register(c *gin.Context) {
 
 	// 查重
 	var count int64
-	models.DB.Model(&models.User{}).Where("username = ?", req.Username).Or("email = ?", req.Email).Count(&count)
+	reqDB(c).Model(&models.User{}).Where("username = ?", req.Username).Or("email = ?", req.Email).Count(&count)
 	if count > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Username or email already exists"})
 		return
@@ -50,7 +52,7 @@ func Register(c *gin.Context) {
 		IsActive:     true,
 	}
 
-	if err := models.DB.Create(user).Error; err != nil {
+	if err := reqDB(c).Create(user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to create user: " + err.Error()})
 		return
 	}
@@ -72,32 +74,35 @@ func Login(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := models.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
+	if err := reqDB(c).Where("username = ?", req.Username).First(&user).Error; err != nil {
+		metrics.AuthFailure(metrics.ReasonInvalidCredentials)
 		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid credentials"})
 		return
 	}
 	if user.PasswordHash == "" {
+		metrics.AuthFailure(metrics.ReasonInvalidCredentials)
 		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Password not set"})
 		return
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		metrics.AuthFailure(metrics.ReasonInvalidCredentials)
 		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid credentials"})
 		return
 	}
 
-	// 生成 JWT
-	secret := os.Getenv("SESSION_SECRET")
-	if secret == "" {
-		secret = "dev-secret"
-	}
-	expires := time.Now().Add(24 * time.Hour)
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": expires.Unix(),
-		"iat": time.Now().Unix(),
+	// 已启用双因素认证：先签发短期挑战令牌，校验验证码后再签发会话 JWT；
+	// 管理员强制启用双因素认证但用户尚未绑定：仅允许进入绑定流程
+	if typ := secondFactorChallengeType(&user); typ != "" {
+		respondTwoFactorChallenge(c, &user, typ)
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	s, err := token.SignedString([]byte(secret))
+
+	respondSession(c, &user)
+}
+
+// respondSession 签发会话 JWT 并返回登录结果
+func respondSession(c *gin.Context, user *models.User) {
+	s, err := issueSessionToken(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
 		return
@@ -109,16 +114,40 @@ func Login(c *gin.Context) {
 	}})
 }
 
+// issueSessionToken 生成 24 小时有效的会话 JWT，sv 为用户当前的会话版本
+func issueSessionToken(user *models.User) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": user.ID,
+		"typ": "session",
+		"sv":  user.SessionVersion,
+		"exp": now.Add(24 * time.Hour).Unix(),
+		"iat": now.Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(sessionSecret()))
+}
+
+// sessionSecret 会话 JWT 签名密钥
+func sessionSecret() string {
+	return config.Get().Session.Secret
+}
+
+// reqDB 绑定请求 context 的数据库会话，SQL 日志会带上请求 ID
+func reqDB(c *gin.Context) *gorm.DB {
+	return models.DB.WithContext(c.Request.Context())
+}
+
 // Me 返回当前认证用户信息
 func Me(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	var user models.User
-	if err := models.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+	if err := reqDB(c).Where("id = ?", userID).First(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to load user: " + err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
 		"id": user.ID, "username": user.Username, "email": user.Email, "isActive": user.IsActive, "createdAt": user.CreatedAt,
+		"isAdmin": user.IsAdmin, "totpEnabled": user.TOTPEnabled,
 	}})
 }
 
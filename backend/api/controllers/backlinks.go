@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Backlink 引用某个引用块片段的分享或片段
+type Backlink struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"` // share / snippet
+	Title    string `json:"title"`
+	ShareURL string `json:"shareUrl"`
+}
+
+// GetShareBacklinks 列出直接引用该引用块片段的分享与片段（公开接口，访问条件与查看片段相同）。
+// 只列出无需密码即可访问的未过期引用方：公开的父分享，以及访问条件无需密码的片段
+func GetShareBacklinks(c *gin.Context) {
+	var snippet models.BlockSnippet
+	if err := reqDB(c).Where("id = ?", c.Param("id")).First(&snippet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query share: " + err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	parents, err := models.SnippetParents(ctx, snippet.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query share: " + err.Error()})
+		return
+	}
+	if len(parents[snippet.ID]) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found"})
+		return
+	}
+	now := time.Now()
+	if _, ok := authorizeShareView(c, snippetAccessOf(parents[snippet.ID], now).grants); !ok {
+		return
+	}
+
+	shares, snippets, err := models.SnippetBacklinks(ctx, &snippet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query backlinks: " + err.Error()})
+		return
+	}
+	backlinks := make([]Backlink, 0, len(shares)+len(snippets))
+	for _, s := range shares {
+		if s.IsPublic && !s.RequirePassword && shareStatus(&s, now) == shareStatusActive {
+			backlinks = append(backlinks, Backlink{ID: s.ID, Kind: treeKindShare, Title: s.DocTitle, ShareURL: ShareURL(c, s.ID)})
+		}
+	}
+	if len(snippets) > 0 {
+		ids := make([]string, len(snippets))
+		for i, s := range snippets {
+			ids[i] = s.ID
+		}
+		snippetParents, err := models.SnippetParents(ctx, ids...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query backlinks: " + err.Error()})
+			return
+		}
+		for _, s := range snippets {
+			access := snippetAccessOf(snippetParents[s.ID], now)
+			if len(snippetParents[s.ID]) > 0 && access.status == shareStatusActive && !access.requirePassword {
+				backlinks = append(backlinks, Backlink{ID: s.ID, Kind: treeKindSnippet, Title: s.Title, ShareURL: ShareURL(c, s.ID)})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "success",
+		"data": gin.H{
+			"id":        snippet.ID,
+			"blockId":   snippet.BlockID,
+			"backlinks": backlinks,
+		},
+	})
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/backup"
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// backupManager 当前配置对应的快照管理器；非 SQLite 时直接写入错误响应并返回 nil
+func backupManager(c *gin.Context) *backup.Manager {
+	mgr, err := backup.New(config.Get(), models.DB)
+	if err != nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"code": 1, "msg": err.Error()})
+		return nil
+	}
+	return mgr
+}
+
+// ListBackups 列出快照
+func ListBackups(c *gin.Context) {
+	mgr := backupManager(c)
+	if mgr == nil {
+		return
+	}
+	list, err := mgr.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list backups: " + err.Error()})
+		return
+	}
+	if list == nil {
+		list = []backup.Manifest{}
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": list}})
+}
+
+// CreateBackup 立即生成一份手动快照（在线执行，不影响服务）
+func CreateBackup(c *gin.Context) {
+	mgr := backupManager(c)
+	if mgr == nil {
+		return
+	}
+	man, err := mgr.Create(backup.KindManual)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to create backup: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": man})
+}
+
+// VerifyBackup 校验快照文件的校验和
+func VerifyBackup(c *gin.Context) {
+	mgr := backupManager(c)
+	if mgr == nil {
+		return
+	}
+	dir, err := mgr.Path(c.Param("name"))
+	if errors.Is(err, backup.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Backup not found"})
+		return
+	}
+	man, err := backup.Verify(dir)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"code": 1, "msg": err.Error(), "data": gin.H{"valid": false}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"valid": true, "manifest": man}})
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+)
+
+// embedPattern 匹配思源嵌入块: {{select * from blocks where id='blockId'}}
+var embedPattern = regexp.MustCompile(`(?i)\{\{\s*select\s+\*\s+from\s+blocks\s+where\s+id\s*=\s*['"]([0-9]{14,}-[0-9a-z]{7,})['"]\s*;?\s*\}\}`)
+
+// contentRenderer 渲染分享或引用块片段的内容：块引用替换为链接，嵌入块展开为引用内容并链接到其片段
+type contentRenderer struct {
+	resolve refResolver
+	// scope 嵌入块的授权范围：外层内容的父分享（分享为其自身）。嵌入的片段必须被其中每个父分享引用，
+	// 嵌入内容随外层内容在同样的密码与过期时间下展示
+	scope   []string
+	baseURL string
+}
+
+// render 渲染内容；path 为正在展开的嵌入片段（检测循环嵌入），depth 为当前嵌入层数
+func (r *contentRenderer) render(ctx context.Context, content string, refs []models.BlockReference, path map[string]bool, depth int) (string, error) {
+	content, err := replaceBlockReferences(ctx, content, refs, r.resolve)
+	if err != nil {
+		return "", err
+	}
+	return r.expandEmbeds(ctx, content, refs, path, depth)
+}
+
+// expandEmbeds 展开内容中的嵌入块。循环嵌入或超过 render.maxRefDepth 层时只显示链接；
+// 不在授权范围内的块可链接时显示链接，否则按 render.unresolvedRefs 渲染
+func (r *contentRenderer) expandEmbeds(ctx context.Context, content string, refs []models.BlockReference, path map[string]bool, depth int) (string, error) {
+	locs := embedPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(locs) == 0 {
+		return content, nil
+	}
+	var blockIDs []string
+	seen := map[string]bool{}
+	for _, loc := range locs {
+		if id := content[loc[2]:loc[3]]; !seen[id] {
+			seen[id] = true
+			blockIDs = append(blockIDs, id)
+		}
+	}
+	snippets, err := models.EmbeddedSnippets(ctx, r.scope, blockIDs)
+	if err != nil {
+		return "", err
+	}
+	links, err := r.resolve(ctx, blockIDs)
+	if err != nil {
+		return "", err
+	}
+	blockMap := make(map[string]models.BlockReference, len(refs))
+	for _, ref := range refs {
+		blockMap[ref.BlockID] = ref
+	}
+
+	render := config.Get().Render
+	var b strings.Builder
+	b.Grow(len(content))
+	last := 0
+	for _, loc := range locs {
+		b.WriteString(content[last:loc[0]])
+		last = loc[1]
+		blockID := content[loc[2]:loc[3]]
+
+		s := snippets[blockID]
+		if s == nil {
+			if link, ok := links[blockID]; ok {
+				b.WriteString("[" + embedTitle(blockMap[blockID].DisplayText, blockMap[blockID].Content) + "](" + link + ")")
+			} else {
+				b.WriteString(renderUnresolvedRef(render, content[loc[0]:loc[1]], "", blockMap[blockID]))
+			}
+			continue
+		}
+		link := shareURLFrom(r.baseURL, s.ID)
+		title := embedTitle(s.Title, s.Content)
+		if path[s.ID] || depth >= render.MaxRefDepth {
+			b.WriteString("[" + title + "](" + link + ")")
+			continue
+		}
+
+		var nested []models.BlockReference
+		if s.References != "" {
+			_ = json.Unmarshal([]byte(s.References), &nested)
+		}
+		path[s.ID] = true
+		inner, err := r.render(ctx, s.Content, nested, path, depth+1)
+		delete(path, s.ID)
+		if err != nil {
+			return "", err
+		}
+
+		// 引用块必须独占段落
+		if loc[0] > 0 && content[loc[0]-1] != '\n' {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(quoteEmbed(inner, title, link))
+		if loc[1] < len(content) && content[loc[1]] != '\n' {
+			b.WriteString("\n\n")
+		}
+	}
+	b.WriteString(content[last:])
+	return b.String(), nil
+}
+
+// quoteEmbed 嵌入内容渲染为 Markdown 引用块，末行链接到嵌入块的片段
+func quoteEmbed(content, title, link string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		if line = strings.TrimRight(line, " \t"); line == "" {
+			b.WriteString(">\n")
+		} else {
+			b.WriteString("> " + line + "\n")
+		}
+	}
+	b.WriteString(">\n> [" + title + "](" + link + ")")
+	return b.String()
+}
+
+// embedTitle 嵌入块链接的显示文本
+func embedTitle(title, content string) string {
+	if title != "" {
+		return title
+	}
+	if text := truncateRunes(strings.TrimSpace(content), 30); text != "" {
+		return text
+	}
+	return "嵌入块"
+}
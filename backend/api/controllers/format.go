@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/kramdown"
+)
+
+// 创建分享时内容的格式
+const (
+	formatMarkdown = "markdown" // 默认：客户端已转换为 Markdown
+	formatKramdown = "kramdown" // 思源导出的 kramdown，由服务端转换
+)
+
+// KramdownReport kramdown 格式内容的转换结果
+type KramdownReport struct {
+	Refs   []kramdown.Ref `json:"refs"`   // 正文中的块引用
+	Embeds []string       `json:"embeds"` // 正文中按块 ID 嵌入的块
+	Tags   []string       `json:"tags"`   // 正文中的标签
+	// Missing 正文、引用块与嵌入块内容中引用或嵌入、但未在 references / embeds / anchors 中提交的块，
+	// 服务端无法读取这些块的内容，按未解析的引用渲染
+	Missing []string `json:"missing"`
+}
+
+// normalizeKramdown 将正文、引用块与嵌入块（含嵌套引用）的 kramdown 转换为 Markdown。
+// 块引用与块嵌入保留给服务端渲染；提交的引用没有显示文本时使用正文中的锚文本
+func normalizeKramdown(req *CreateShareRequest) *KramdownReport {
+	opts := kramdown.Options{PreserveBlockRefs: true, PreserveEmbeds: true}
+	doc := kramdown.Normalize(req.Content, opts)
+	req.Content = doc.Markdown
+	report := &KramdownReport{Refs: nonNil(doc.Refs), Embeds: nonNil(doc.Embeds), Tags: nonNil(doc.Tags), Missing: []string{}}
+
+	used := map[string]bool{}
+	var order []string
+	use := func(n kramdown.Normalized) {
+		for _, r := range n.Refs {
+			order = append(order, r.BlockID)
+		}
+		order = append(order, n.Embeds...)
+	}
+	use(doc)
+
+	anchorText := make(map[string]string, len(doc.Refs))
+	for _, r := range doc.Refs {
+		anchorText[r.BlockID] = r.DisplayText
+	}
+	submitted := map[string]bool{}
+	var normalize func(refs []BlockReferenceReq)
+	normalize = func(refs []BlockReferenceReq) {
+		for i := range refs {
+			id := strings.TrimSpace(refs[i].BlockID)
+			submitted[id] = true
+			n := kramdown.Normalize(refs[i].Content, opts)
+			refs[i].Content = n.Markdown
+			if refs[i].DisplayText == "" {
+				refs[i].DisplayText = anchorText[id]
+			}
+			use(n)
+			normalize(refs[i].References)
+		}
+	}
+	normalize(req.References)
+	normalize(req.Embeds)
+
+	for _, id := range order {
+		if !used[id] && !submitted[id] && req.Anchors[id] == "" {
+			report.Missing = append(report.Missing, id)
+		}
+		used[id] = true
+	}
+	return report
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// draining 收到停止信号后置为 true，就绪探针随即返回 503，让负载均衡摘除实例
+var draining atomic.Bool
+
+// SetDraining 标记服务进入停机排空阶段
+func SetDraining() { draining.Store(true) }
+
+// Liveness 存活探针：进程能处理请求即返回 200，不依赖外部资源
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"ts":      time.Now().Unix(),
+		"version": "v1", // 可后续从构建信息注入
+	})
+}
+
+// Readiness 就绪探针：检查数据库连通性，停机排空期间返回 503
+func Readiness(c *gin.Context) {
+	if draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+	if err := models.Ping(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": gin.H{"database": "unreachable"}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": gin.H{"database": "ok"}})
+}
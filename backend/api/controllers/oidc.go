@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/oidc"
+	"github.com/ZeroHawkeye/siyuan-share-api/proxy"
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+	oidcCookiePath  = "/api/auth/oidc"
+)
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+
+	usernameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// loadOIDCSettings 读取 OIDC 配置及是否启用
+func loadOIDCSettings() (config.OIDCConfig, bool) {
+	s := config.Get().OIDC
+	return s, s.Enabled()
+}
+
+// getOIDCProvider 懒加载并缓存身份提供方（发现失败时不缓存，下次请求重试）
+func getOIDCProvider(c *gin.Context, settings config.OIDCConfig) (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProvider == nil {
+		p, err := oidc.Discover(c.Request.Context(), oidc.Config{
+			Issuer:       settings.Issuer,
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  settings.RedirectURL,
+			Scopes:       settings.Scopes,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		oidcProvider = p
+	}
+	if settings.RedirectURL != "" {
+		return oidcProvider, nil
+	}
+	return oidcProvider.WithRedirectURL(BaseURL(c) + oidcCookiePath + "/callback"), nil
+}
+
+// OIDCConfig 返回 SSO 是否启用及按钮名称，供前端展示
+func OIDCConfig(c *gin.Context) {
+	settings, enabled := loadOIDCSettings()
+	data := gin.H{"enabled": enabled}
+	if enabled {
+		data["name"] = settings.DisplayName
+		data["loginUrl"] = publicPath(oidcCookiePath + "/login")
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": data})
+}
+
+// OIDCLogin 发起授权码 + PKCE 登录，跳转到身份提供方
+func OIDCLogin(c *gin.Context) {
+	settings, enabled := loadOIDCSettings()
+	if !enabled {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "SSO is not configured"})
+		return
+	}
+	provider, err := getOIDCProvider(c, settings)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": 1, "msg": "SSO provider unavailable: " + err.Error()})
+		return
+	}
+
+	state := oidc.RandomString(24)
+	nonce := oidc.RandomString(24)
+	verifier := oidc.RandomString(48)
+	claims := jwt.MapClaims{
+		"typ":      "oidc_state",
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"redirect": safeRedirectPath(c.Query("redirect")),
+		"exp":      time.Now().Add(oidcStateTTL).Unix(),
+	}
+	cookie, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(sessionSecret()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign state"})
+		return
+	}
+	setOIDCStateCookie(c, cookie, int(oidcStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, nonce, verifier))
+}
+
+// OIDCCallback 处理身份提供方回调：校验 state、交换令牌、校验 ID Token、关联本地用户并签发会话 JWT。
+// 成功后跳转到前端页面，会话令牌放在 URL fragment（#token=...）中，不会发送到服务端日志。
+func OIDCCallback(c *gin.Context) {
+	settings, enabled := loadOIDCSettings()
+	if !enabled {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "SSO is not configured"})
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		metrics.AuthFailure(metrics.ReasonSSO)
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "SSO login failed: " + e + " " + c.Query("error_description")})
+		return
+	}
+
+	raw, err := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if err != nil {
+		metrics.AuthFailure(metrics.ReasonSSO)
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Missing SSO state, please retry login"})
+		return
+	}
+	state, err := parseOIDCState(raw)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state["state"]), []byte(c.Query("state"))) != 1 {
+		metrics.AuthFailure(metrics.ReasonSSO)
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid SSO state, please retry login"})
+		return
+	}
+
+	provider, err := getOIDCProvider(c, settings)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": 1, "msg": "SSO provider unavailable: " + err.Error()})
+		return
+	}
+	tokens, err := provider.Exchange(c.Request.Context(), c.Query("code"), state["verifier"])
+	if err != nil {
+		metrics.AuthFailure(metrics.ReasonSSO)
+		c.JSON(http.StatusBadGateway, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	claims, err := provider.VerifyIDToken(c.Request.Context(), tokens.IDToken, state["nonce"])
+	if err != nil {
+		metrics.AuthFailure(metrics.ReasonSSO)
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+
+	user, err := resolveOIDCUser(provider.Metadata().Issuer, claims, settings)
+	if err != nil {
+		metrics.AuthFailure(metrics.ReasonSSO)
+		c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+
+	target := publicPath(safeRedirectPath(state["redirect"]))
+	// 与密码登录一致：已启用 2FA 或策略强制绑定时只下发挑战令牌，由前端继续 /api/auth/2fa/* 流程
+	if typ := secondFactorChallengeType(user); typ != "" {
+		s, err := signChallengeToken(user, typ)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+			return
+		}
+		fragment := url.Values{"challengeToken": {s}}
+		if typ == challengeTypeEnroll {
+			fragment.Set("setupRequired", "true")
+		}
+		c.Redirect(http.StatusFound, target+"#"+fragment.Encode())
+		return
+	}
+	s, err := issueSessionToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+		return
+	}
+	c.Redirect(http.StatusFound, target+"#token="+url.QueryEscape(s))
+}
+
+// resolveOIDCUser 按 (issuer, subject) 查找已绑定用户；首次登录按邮箱关联（IdP 须声明 email_verified=true）或自动创建
+func resolveOIDCUser(issuer string, claims *oidc.Claims, settings config.OIDCConfig) (*models.User, error) {
+	var user models.User
+	now := time.Now()
+
+	var identity models.UserIdentity
+	err := models.DB.Where("issuer = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error
+	switch {
+	case err == nil:
+		if err := models.DB.Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+			return nil, errors.New("linked user not found")
+		}
+		models.DB.Model(&identity).Updates(map[string]interface{}{"last_login_at": &now, "email": claims.Email})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		email := strings.TrimSpace(claims.Email)
+		if email == "" {
+			return nil, errors.New("identity provider did not return an email address")
+		}
+		if claims.EmailVerified != nil && !*claims.EmailVerified {
+			return nil, errors.New("email address is not verified by identity provider")
+		}
+		err := models.DB.Transaction(func(tx *gorm.DB) error {
+			lookup := tx.Where("LOWER(email) = ?", strings.ToLower(email)).First(&user)
+			if lookup.Error == nil && (claims.EmailVerified == nil || !*claims.EmailVerified) {
+				// 未声明已验证的邮箱不足以证明账户归属，否则任何 IdP 账户都可接管同邮箱的本地用户
+				return errors.New("email address must be verified by identity provider to link an existing account")
+			}
+			if errors.Is(lookup.Error, gorm.ErrRecordNotFound) {
+				if !settings.AutoProvision {
+					return errors.New("no local account for " + email)
+				}
+				username, err := uniqueUsername(tx, claims, email)
+				if err != nil {
+					return err
+				}
+				user = models.User{
+					ID:       "user_" + randHex(16),
+					Username: username,
+					Email:    email,
+					IsActive: true,
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+			} else if lookup.Error != nil {
+				return lookup.Error
+			}
+			return tx.Create(&models.UserIdentity{
+				ID:          "idt_" + randHex(12),
+				UserID:      user.ID,
+				Issuer:      issuer,
+				Subject:     claims.Subject,
+				Email:       email,
+				LastLoginAt: &now,
+			}).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("user is inactive")
+	}
+
+	// 配置了管理员组时，以 IdP 的组成员关系为准同步管理员身份
+	if len(settings.AdminGroups) > 0 {
+		isAdmin := false
+		for _, g := range claims.Strings(settings.GroupsClaim) {
+			for _, admin := range settings.AdminGroups {
+				if g == admin {
+					isAdmin = true
+				}
+			}
+		}
+		if isAdmin != user.IsAdmin {
+			if err := models.DB.Model(&user).Update("is_admin", isAdmin).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &user, nil
+}
+
+// uniqueUsername 根据 preferred_username 或邮箱前缀生成不重复的用户名
+func uniqueUsername(tx *gorm.DB, claims *oidc.Claims, email string) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	base = strings.Trim(usernameSanitizer.ReplaceAllString(base, "-"), "-")
+	if len(base) > 80 {
+		base = base[:80]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+	candidate := base
+	for i := 0; i < 10; i++ {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = base + "-" + randHex(2)
+	}
+	return "", errors.New("failed to allocate username")
+}
+
+func parseOIDCState(raw string) (map[string]string, error) {
+	tok, err := jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		return []byte(sessionSecret()), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil || !tok.Valid {
+		return nil, errors.New("invalid state")
+	}
+	mc, _ := tok.Claims.(jwt.MapClaims)
+	if typ, _ := mc["typ"].(string); typ != "oidc_state" {
+		return nil, errors.New("invalid state")
+	}
+	out := map[string]string{}
+	for _, k := range []string{"state", "nonce", "verifier", "redirect"} {
+		out[k], _ = mc[k].(string)
+	}
+	return out, nil
+}
+
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := proxy.Scheme(c.Request) == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, publicPath(oidcCookiePath), "", secure, true)
+}
+
+// safeRedirectPath 仅允许站内相对路径，防止开放重定向。
+// 浏览器会忽略 URL 中的制表符与换行（/\t/evil.com 等同于 //evil.com），因此拒绝所有控制字符与空白
+func safeRedirectPath(p string) string {
+	if p == "" || !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.Contains(p, "\\") {
+		return "/"
+	}
+	for _, r := range p {
+		if r <= ' ' || r == 0x7f || unicode.IsSpace(r) {
+			return "/"
+		}
+	}
+	if i := strings.IndexByte(p, '#'); i >= 0 {
+		p = p[:i]
+	}
+	if u, err := url.Parse(p); err != nil || u.Scheme != "" || u.Host != "" || u.User != nil {
+		return "/"
+	}
+	return p
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import "testing"
+
+func TestSafeRedirectPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"/s/abc", "/s/abc"},
+		{"/notes/settings?tab=2fa", "/notes/settings?tab=2fa"},
+		{"/s/abc#top", "/s/abc"},
+		{"s/abc", "/"},
+		{"https://evil.example/", "/"},
+		{"//evil.example", "/"},
+		{"/\\evil.example", "/"},
+		{"\\\\evil.example", "/"},
+		{"/\t/evil.example", "/"},
+		{"/\n/evil.example", "/"},
+		{"/ /evil.example", "/"},
+		{"/　/evil.example", "/"},
+		{"/%2F/evil.example", "/%2F/evil.example"},
+		{"javascript:alert(1)", "/"},
+	}
+	for _, tt := range tests {
+		if got := safeRedirectPath(tt.in); got != tt.want {
+			t.Errorf("safeRedirectPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/transform"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func pipelineSettingsData(s *models.PipelineSettings) gin.H {
+	p, _ := transform.Decode(s.Pipeline)
+	if p == nil {
+		p = &transform.Pipeline{}
+	}
+	defs := []gin.H{}
+	for _, d := range transform.Definitions() {
+		defs = append(defs, gin.H{"name": d.Name, "description": d.Description, "stages": d.Stages})
+	}
+	cfg := config.Get()
+	data := gin.H{
+		"publish":      p.Publish,
+		"view":         p.View,
+		"defaults":     gin.H{"publish": nonNil(cfg.Publish.Transformers), "view": nonNil(cfg.Render.Transformers)},
+		"transformers": defs,
+	}
+	if !s.UpdatedAt.IsZero() {
+		data["updatedAt"] = s.UpdatedAt
+	}
+	return data
+}
+
+// GetPipelineSettings 获取当前用户的内容转换流水线
+func GetPipelineSettings(c *gin.Context) {
+	s, err := models.GetPipelineSettings(reqDB(c), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query pipeline settings: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": pipelineSettingsData(s)})
+}
+
+// UpdatePipelineSettings 设置当前用户的内容转换流水线，阶段为 null 时使用服务端默认
+func UpdatePipelineSettings(c *gin.Context) {
+	var req transform.Pipeline
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid pipeline: " + err.Error()})
+		return
+	}
+	s, err := models.GetPipelineSettings(reqDB(c), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query pipeline settings: " + err.Error()})
+		return
+	}
+	s.Pipeline = transform.Encode(&req)
+	s.UpdatedAt = time.Now()
+	if err := reqDB(c).Save(s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to save pipeline settings: " + err.Error()})
+		return
+	}
+	// 查看阶段的结果在渲染缓存中，用户的全部分享都受影响
+	PurgeShareCache()
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": pipelineSettingsData(s)})
+}
+
+// userPipeline 用户的流水线设置，未设置或没有所属用户（团队片段）时为 nil
+func userPipeline(db *gorm.DB, userID string) (*transform.Pipeline, error) {
+	if userID == "" {
+		return nil, nil
+	}
+	s, err := models.GetPipelineSettings(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return transform.Decode(s.Pipeline)
+}
+
+// publishChain 发布阶段的流水线：分享设置优先，其次用户设置，最后为 publish.transformers
+func publishChain(db *gorm.DB, userID string, share *transform.Pipeline) (transform.Chain, error) {
+	user, err := userPipeline(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return transform.Build(transform.StagePublish, transform.Select(transform.StagePublish, config.Get().Publish.Transformers, share, user))
+}
+
+// runPublishTransformers 执行发布阶段转换：正文为文档分享，引用块与嵌入块（含嵌套引用）为引用块片段
+func runPublishTransformers(ctx context.Context, chain transform.Chain, req *CreateShareRequest, shareID string) error {
+	if len(chain) == 0 {
+		return nil
+	}
+	doc := &transform.Document{Stage: transform.StagePublish, Kind: transform.KindShare, ID: shareID, Title: req.DocTitle, Content: req.Content}
+	if err := chain.Run(ctx, doc); err != nil {
+		return err
+	}
+	req.DocTitle, req.Content = doc.Title, doc.Content
+
+	var run func(refs []BlockReferenceReq) error
+	run = func(refs []BlockReferenceReq) error {
+		for i := range refs {
+			doc := &transform.Document{Stage: transform.StagePublish, Kind: transform.KindSnippet, BlockID: strings.TrimSpace(refs[i].BlockID),
+				Title: refs[i].DisplayText, Content: refs[i].Content}
+			if err := chain.Run(ctx, doc); err != nil {
+				return err
+			}
+			refs[i].Title, refs[i].Content = doc.Title, doc.Content
+			if err := run(refs[i].References); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := run(req.References); err != nil {
+		return err
+	}
+	return run(req.Embeds)
+}
+
+// runViewTransformers 执行查看阶段转换：分享设置优先，其次所有者的用户设置，最后为 render.transformers。
+// 已保存的设置失效（如转换器已移除）时记录警告并使用服务端默认，不影响分享的访问
+func runViewTransformers(c *gin.Context, ownerID, sharePipeline string, doc *transform.Document) error {
+	user, err := userPipeline(reqDB(c), ownerID)
+	if err != nil {
+		return err
+	}
+	defaults := config.Get().Render.Transformers
+	share, err := transform.Decode(sharePipeline)
+	var chain transform.Chain
+	if err == nil {
+		chain, err = transform.Build(transform.StageView, transform.Select(transform.StageView, defaults, share, user))
+	}
+	if err != nil {
+		slog.Warn("Invalid view pipeline, using defaults", "id", doc.ID, "error", err)
+		if chain, err = transform.Build(transform.StageView, transform.Select(transform.StageView, defaults)); err != nil {
+			return err
+		}
+	}
+	return chain.Run(c.Request.Context(), doc)
+}
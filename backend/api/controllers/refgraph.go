@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/kramdown"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+)
+
+// publishRef 待发布的引用块及其在引用图中的位置
+type publishRef struct {
+	BlockReferenceReq
+	depth int      // 距文档的最短引用层数，文档直接引用为 1
+	refs  []string // 该块引用的其他待发布块（嵌套 references 与内容中的 ((id))）
+}
+
+// planReferences 在写入前校验并展开引用块（含嵌套 references），按引用图计算每个块的层数。
+// 块 ID 格式错误的记为失败；引用文档自身、重复、内容为空、引用自身祖先（循环）、超过 maxDepth 层的记为跳过。
+// 同一个块只发布一次，块之间的循环引用在查看时互相链接，不会递归展开。
+// anchors 中的块属于文档本身，查看时链接到页内锚点，不发布为片段，其嵌套引用视为文档直接引用
+func planReferences(docID, content string, refs []BlockReferenceReq, anchors map[string]string, maxDepth int, report *PublishReport) []*publishRef {
+	nodes := map[string]*publishRef{}
+	var order, roots []string
+	skip := func(id, reason string) {
+		report.Skipped = append(report.Skipped, PublishRefResult{BlockID: id, Reason: reason})
+	}
+
+	// 展开请求中的嵌套引用；path 为当前块的祖先，用于检测循环
+	path := map[string]bool{}
+	inDoc := map[string]bool{}
+	var walk func(list []BlockReferenceReq, parent *publishRef)
+	walk = func(list []BlockReferenceReq, parent *publishRef) {
+		for _, ref := range list {
+			ref.BlockID = strings.TrimSpace(ref.BlockID)
+			switch {
+			case !blockIDPattern.MatchString(ref.BlockID):
+				report.Failed = append(report.Failed, PublishRefResult{BlockID: ref.BlockID, Reason: refReasonInvalidID})
+				continue
+			case ref.BlockID == docID:
+				skip(ref.BlockID, refReasonSelf)
+				continue
+			case path[ref.BlockID]:
+				skip(ref.BlockID, refReasonCyclic)
+				continue
+			case anchors[ref.BlockID] != "":
+				if !inDoc[ref.BlockID] {
+					inDoc[ref.BlockID] = true
+					skip(ref.BlockID, refReasonInDoc)
+					walk(ref.References, nil)
+				}
+				continue
+			}
+			if _, ok := nodes[ref.BlockID]; ok {
+				if parent == nil {
+					skip(ref.BlockID, refReasonDuplicate)
+				} else {
+					parent.refs = append(parent.refs, ref.BlockID)
+				}
+				continue
+			}
+			if strings.TrimSpace(ref.Content) == "" {
+				skip(ref.BlockID, refReasonEmpty)
+				continue
+			}
+
+			n := &publishRef{BlockReferenceReq: ref}
+			nodes[ref.BlockID] = n
+			order = append(order, ref.BlockID)
+			if parent == nil {
+				roots = append(roots, ref.BlockID)
+			} else {
+				parent.refs = append(parent.refs, ref.BlockID)
+			}
+			if len(ref.References) == 0 {
+				continue
+			}
+			if len(path)+1 >= maxDepth {
+				for _, child := range ref.References {
+					skip(strings.TrimSpace(child.BlockID), refReasonTooDeep)
+				}
+				continue
+			}
+			path[ref.BlockID] = true
+			walk(ref.References, n)
+			delete(path, ref.BlockID)
+		}
+	}
+	walk(refs, nil)
+
+	// 内容中的 ((id)) 与嵌入块同样是引用图的边（插件以扁平列表提交嵌套引用）
+	for _, id := range blockRefIDs(content) {
+		if nodes[id] != nil {
+			roots = append(roots, id)
+		}
+	}
+	for _, id := range order {
+		n := nodes[id]
+		for _, ref := range blockRefIDs(n.Content) {
+			if ref != id && nodes[ref] != nil {
+				n.refs = append(n.refs, ref)
+			}
+		}
+	}
+
+	// 广度优先计算最短层数
+	queue := make([]string, 0, len(nodes))
+	for _, id := range roots {
+		if nodes[id].depth == 0 {
+			nodes[id].depth = 1
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		n := nodes[queue[0]]
+		queue = queue[1:]
+		for _, ref := range n.refs {
+			if nodes[ref].depth == 0 {
+				nodes[ref].depth = n.depth + 1
+				queue = append(queue, ref)
+			}
+		}
+	}
+
+	planned := make([]*publishRef, 0, len(order))
+	for _, id := range order {
+		if n := nodes[id]; n.depth > maxDepth {
+			skip(id, refReasonTooDeep)
+			continue
+		}
+		planned = append(planned, nodes[id])
+	}
+	for _, n := range planned {
+		n.refs = uniqueRefs(n.refs, func(id string) bool { return nodes[id].depth <= maxDepth })
+	}
+	return planned
+}
+
+// stripHiddenBlocks 移除正文与引用块内容中带有 publish.hideAttribute 属性的块及其子块，
+// 并丢弃指向被移除块的引用、嵌入与锚点。返回移除的块数
+func stripHiddenBlocks(req *CreateShareRequest) int {
+	name, value := config.Get().Publish.HideAttr()
+	if name == "" {
+		return 0
+	}
+	hidden := map[string]bool{}
+	count := 0
+	strip := func(content string) string {
+		res := kramdown.StripBlocks(content, name, value)
+		for _, id := range res.BlockIDs {
+			hidden[id] = true
+		}
+		count += res.Blocks
+		return res.Content
+	}
+
+	req.Content = strip(req.Content)
+	var stripRefs func(refs []BlockReferenceReq) []BlockReferenceReq
+	stripRefs = func(refs []BlockReferenceReq) []BlockReferenceReq {
+		for i := range refs {
+			refs[i].Content = strip(refs[i].Content)
+			refs[i].References = stripRefs(refs[i].References)
+		}
+		return refs
+	}
+	req.References = stripRefs(req.References)
+	req.Embeds = stripRefs(req.Embeds)
+	if len(hidden) == 0 {
+		return count
+	}
+
+	var drop func(refs []BlockReferenceReq) []BlockReferenceReq
+	drop = func(refs []BlockReferenceReq) []BlockReferenceReq {
+		kept := refs[:0]
+		for _, ref := range refs {
+			if !hidden[strings.TrimSpace(ref.BlockID)] {
+				ref.References = drop(ref.References)
+				kept = append(kept, ref)
+			}
+		}
+		return kept
+	}
+	req.References = drop(req.References)
+	req.Embeds = drop(req.Embeds)
+	for blockID := range req.Anchors {
+		if hidden[strings.TrimSpace(blockID)] {
+			delete(req.Anchors, blockID)
+		}
+	}
+	return count
+}
+
+// validateAnchors 校验同一文档内的块与锚点，块 ID 或锚点无效时记为失败
+func validateAnchors(anchors map[string]string, report *PublishReport) map[string]string {
+	valid := make(map[string]string, len(anchors))
+	blockIDs := make([]string, 0, len(anchors))
+	for blockID := range anchors {
+		blockIDs = append(blockIDs, blockID)
+	}
+	sort.Strings(blockIDs)
+	for _, blockID := range blockIDs {
+		anchor := strings.TrimPrefix(strings.TrimSpace(anchors[blockID]), "#")
+		blockID = strings.TrimSpace(blockID)
+		switch {
+		case !blockIDPattern.MatchString(blockID):
+			report.Failed = append(report.Failed, PublishRefResult{BlockID: blockID, Reason: refReasonInvalidID})
+		case !anchorPattern.MatchString(anchor):
+			report.Failed = append(report.Failed, PublishRefResult{BlockID: blockID, Reason: refReasonAnchor})
+		default:
+			valid[blockID] = anchor
+		}
+	}
+	return valid
+}
+
+// blockRefIDs 内容中块引用与嵌入块的块 ID（可能重复）
+func blockRefIDs(content string) []string {
+	var ids []string
+	for _, m := range blockRefPattern.FindAllStringSubmatch(content, -1) {
+		ids = append(ids, m[1])
+	}
+	for _, m := range embedPattern.FindAllStringSubmatch(content, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+// embedsToPublish 嵌入块中未同时作为引用块提交的部分（既引用又嵌入的块只发布一次，不记为重复）
+func embedsToPublish(refs, embeds []BlockReferenceReq) []BlockReferenceReq {
+	if len(embeds) == 0 {
+		return nil
+	}
+	referenced := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		referenced[strings.TrimSpace(ref.BlockID)] = true
+	}
+	var out []BlockReferenceReq
+	for _, e := range embeds {
+		if !referenced[strings.TrimSpace(e.BlockID)] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// uniqueRefs 去重并保留 keep 为真的块 ID
+func uniqueRefs(ids []string, keep func(string) bool) []string {
+	seen := make(map[string]bool, len(ids))
+	out := ids[:0]
+	for _, id := range ids {
+		if !seen[id] && keep(id) {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// referencesJSON 片段引用的块（块 ID 与显示文本），无引用时为空字符串
+func (p *publishRef) referencesJSON(byID map[string]*publishRef) string {
+	if len(p.refs) == 0 {
+		return ""
+	}
+	refs := make([]models.BlockReference, len(p.refs))
+	for i, id := range p.refs {
+		refs[i] = models.BlockReference{BlockID: id, DisplayText: byID[id].DisplayText}
+	}
+	b, _ := json.Marshal(refs)
+	return string(b)
+}
@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/scanner"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 自定义检测规则的限制
+const (
+	maxScanRules      = 50
+	maxScanAllowlist  = 100
+	maxScanPatternLen = 500
+)
+
+var scanRuleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// ScanSettingsRequest 更新发布前检测设置，省略的字段保持不变
+type ScanSettingsRequest struct {
+	Mode          *string            `json:"mode"` // off / warn / block / redact，空字符串表示使用服务端默认
+	CustomRules   *[]models.ScanRule `json:"customRules"`
+	Allowlist     *[]string          `json:"allowlist"`
+	DisabledRules *[]string          `json:"disabledRules"` // 停用的内置规则 ID
+}
+
+// ScanReport 发布前检测结果。位置按提交的原文计算，redact 模式下保存的内容已替换为占位符
+type ScanReport struct {
+	Mode     string        `json:"mode"`
+	Redacted bool          `json:"redacted"`
+	Findings []ScanFinding `json:"findings"`
+}
+
+// ScanFinding 检测结果及其来源
+type ScanFinding struct {
+	Source  string `json:"source"`            // title / content / reference / embed
+	BlockID string `json:"blockId,omitempty"` // 来源为引用块或嵌入块时的块 ID
+	scanner.Finding
+}
+
+// scanRuleSet 解码后的用户检测设置
+type scanRuleSet struct {
+	Mode          string
+	CustomRules   []models.ScanRule
+	Allowlist     []string
+	DisabledRules []string
+}
+
+func decodeScanSettings(s *models.ScanSettings) scanRuleSet {
+	rs := scanRuleSet{Mode: s.Mode}
+	// 已保存的设置写入前均已校验，解码失败时视为未设置
+	_ = json.Unmarshal([]byte(s.CustomRules), &rs.CustomRules)
+	_ = json.Unmarshal([]byte(s.Allowlist), &rs.Allowlist)
+	_ = json.Unmarshal([]byte(s.DisabledRules), &rs.DisabledRules)
+	return rs
+}
+
+// effectiveMode 用户设置优先，未设置时使用 publish.scanMode
+func (rs scanRuleSet) effectiveMode() string {
+	if rs.Mode != "" {
+		return rs.Mode
+	}
+	return config.Get().Publish.ScanMode
+}
+
+// scanner 按设置创建检测器：未停用的内置规则加自定义规则
+func (rs scanRuleSet) scanner() (*scanner.Scanner, error) {
+	disabled := make(map[string]bool, len(rs.DisabledRules))
+	for _, id := range rs.DisabledRules {
+		disabled[id] = true
+	}
+	var rules []scanner.Rule
+	for _, r := range scanner.BuiltinRules() {
+		if !disabled[r.ID] {
+			rules = append(rules, r)
+		}
+	}
+	for _, cr := range rs.CustomRules {
+		r, err := scanner.CustomRule(cr.Name, cr.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	allow := make([]*regexp.Regexp, 0, len(rs.Allowlist))
+	for _, p := range rs.Allowlist {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("allowlist %q: %w", p, err)
+		}
+		allow = append(allow, re)
+	}
+	return scanner.New(rules, allow), nil
+}
+
+// validate 校验待保存的设置
+func (rs scanRuleSet) validate() error {
+	if rs.Mode != "" && !config.ValidScanMode(rs.Mode) {
+		return fmt.Errorf("mode must be off, warn, block or redact, got %q", rs.Mode)
+	}
+	if len(rs.CustomRules) > maxScanRules {
+		return fmt.Errorf("at most %d custom rules", maxScanRules)
+	}
+	if len(rs.Allowlist) > maxScanAllowlist {
+		return fmt.Errorf("at most %d allowlist entries", maxScanAllowlist)
+	}
+	names := map[string]bool{}
+	for _, r := range rs.CustomRules {
+		if !scanRuleNamePattern.MatchString(r.Name) {
+			return fmt.Errorf("invalid rule name %q", r.Name)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate rule name %q", r.Name)
+		}
+		names[r.Name] = true
+		if r.Pattern == "" || len(r.Pattern) > maxScanPatternLen {
+			return fmt.Errorf("rule %q: pattern must be 1-%d characters", r.Name, maxScanPatternLen)
+		}
+	}
+	for _, p := range rs.Allowlist {
+		if p == "" || len(p) > maxScanPatternLen {
+			return fmt.Errorf("allowlist entries must be 1-%d characters", maxScanPatternLen)
+		}
+	}
+	builtin := map[string]bool{}
+	for _, r := range scanner.BuiltinRules() {
+		builtin[r.ID] = true
+	}
+	for _, id := range rs.DisabledRules {
+		if !builtin[id] {
+			return fmt.Errorf("unknown built-in rule %q", id)
+		}
+	}
+	// 编译全部正则
+	_, err := rs.scanner()
+	return err
+}
+
+func scanSettingsData(s *models.ScanSettings) gin.H {
+	rs := decodeScanSettings(s)
+	builtin := []gin.H{}
+	for _, r := range scanner.BuiltinRules() {
+		builtin = append(builtin, gin.H{"id": r.ID, "name": r.Name})
+	}
+	data := gin.H{
+		"mode":          rs.Mode,
+		"effectiveMode": rs.effectiveMode(),
+		"customRules":   nonNil(rs.CustomRules),
+		"allowlist":     nonNil(rs.Allowlist),
+		"disabledRules": nonNil(rs.DisabledRules),
+		"builtinRules":  builtin,
+	}
+	if !s.UpdatedAt.IsZero() {
+		data["updatedAt"] = s.UpdatedAt
+	}
+	return data
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// GetScanSettings 获取当前用户的发布前检测设置
+func GetScanSettings(c *gin.Context) {
+	userID := c.GetString("userID")
+	s, err := models.GetScanSettings(reqDB(c), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query scan settings: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": scanSettingsData(s)})
+}
+
+// UpdateScanSettings 更新当前用户的发布前检测设置
+func UpdateScanSettings(c *gin.Context) {
+	var req ScanSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	userID := c.GetString("userID")
+	s, err := models.GetScanSettings(reqDB(c), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query scan settings: " + err.Error()})
+		return
+	}
+
+	rs := decodeScanSettings(s)
+	if req.Mode != nil {
+		rs.Mode = strings.ToLower(strings.TrimSpace(*req.Mode))
+	}
+	if req.CustomRules != nil {
+		rs.CustomRules = *req.CustomRules
+		for i := range rs.CustomRules {
+			rs.CustomRules[i].Name = strings.TrimSpace(rs.CustomRules[i].Name)
+		}
+	}
+	if req.Allowlist != nil {
+		rs.Allowlist = *req.Allowlist
+	}
+	if req.DisabledRules != nil {
+		rs.DisabledRules = *req.DisabledRules
+	}
+	if err := rs.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid scan settings: " + err.Error()})
+		return
+	}
+
+	s.Mode = rs.Mode
+	s.CustomRules = jsonOrEmpty(rs.CustomRules)
+	s.Allowlist = jsonOrEmpty(rs.Allowlist)
+	s.DisabledRules = jsonOrEmpty(rs.DisabledRules)
+	s.UpdatedAt = time.Now()
+	if err := reqDB(c).Save(s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to save scan settings: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": scanSettingsData(s)})
+}
+
+func jsonOrEmpty[T any](s []T) string {
+	if len(s) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// scanShareRequest 按用户设置检测待发布的标题、正文、引用块与嵌入块（含嵌套引用）。
+// 检测关闭时返回 nil；redact 模式下直接替换 req 中的敏感内容
+func scanShareRequest(db *gorm.DB, userID string, req *CreateShareRequest) (*ScanReport, error) {
+	s, err := models.GetScanSettings(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	rs := decodeScanSettings(s)
+	mode := rs.effectiveMode()
+	if mode == config.ScanModeOff {
+		return nil, nil
+	}
+	sc, err := rs.scanner()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScanReport{Mode: mode, Findings: []ScanFinding{}}
+	scan := func(text *string, source, blockID string) {
+		found := sc.Scan(*text)
+		for _, f := range found {
+			report.Findings = append(report.Findings, ScanFinding{Source: source, BlockID: blockID, Finding: f})
+		}
+		if mode == config.ScanModeRedact && len(found) > 0 {
+			*text = scanner.Redact(*text, found)
+			report.Redacted = true
+		}
+	}
+
+	scan(&req.DocTitle, "title", "")
+	scan(&req.Content, "content", "")
+	var scanRefs func(refs []BlockReferenceReq, source string)
+	scanRefs = func(refs []BlockReferenceReq, source string) {
+		for i := range refs {
+			id := strings.TrimSpace(refs[i].BlockID)
+			scan(&refs[i].Content, source, id)
+			// 标题未经转换改写时与显示文本相同，只检测一次
+			sameTitle := refs[i].Title == refs[i].DisplayText
+			scan(&refs[i].DisplayText, source, id)
+			if sameTitle {
+				refs[i].Title = refs[i].DisplayText
+			} else {
+				scan(&refs[i].Title, source, id)
+			}
+			scanRefs(refs[i].References, "reference")
+		}
+	}
+	scanRefs(req.References, "reference")
+	scanRefs(req.Embeds, "embed")
+	return report, nil
+}
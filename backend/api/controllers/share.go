@@ -1,19 +1,26 @@
 package controllers
 
 import (
+	"cmp"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
 	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/transform"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // CreateShareRequest 创建分享请求
@@ -21,11 +28,20 @@ type CreateShareRequest struct {
 	DocID           string              `json:"docId" binding:"required"`
 	DocTitle        string              `json:"docTitle" binding:"required"`
 	Content         string              `json:"content" binding:"required"`
+	Format          string              `json:"format" binding:"omitempty,oneof=markdown kramdown"` // 内容格式：markdown（默认）/ kramdown（由服务端转换）
 	RequirePassword bool                `json:"requirePassword"`
 	Password        string              `json:"password"`
 	ExpireDays      int                 `json:"expireDays" binding:"required,min=1,max=365"`
 	IsPublic        bool                `json:"isPublic"`
+	AllowEmbed      bool                `json:"allowEmbed"` // 允许其他站点以 iframe 嵌入分享页
+	TeamID          string              `json:"teamId"`     // 可选：发布为团队分享（需团队编辑权限）
 	References      []BlockReferenceReq `json:"references"` // 引用块数据
+	// Embeds 嵌入块 {{select * from blocks where id='...'}} 的块数据，与引用块一样发布为片段，查看时内联展示
+	Embeds []BlockReferenceReq `json:"embeds,omitempty"`
+	// Anchors 同一文档内的块 → 渲染后页面中的锚点（元素 id），引用这些块时链接到页内锚点而非引用块片段
+	Anchors map[string]string `json:"anchors,omitempty"`
+	// Pipeline 分享的内容转换流水线，阶段为 null 时继承用户设置；省略时更新分享保留原有设置
+	Pipeline *transform.Pipeline `json:"pipeline,omitempty"`
 }
 
 // BlockReferenceReq 引用块请求数据
@@ -34,20 +50,82 @@ type BlockReferenceReq struct {
 	Content     string `json:"content"`
 	DisplayText string `json:"displayText,omitempty"`
 	RefCount    int    `json:"refCount,omitempty"`
+	// Title 片段标题：显示文本经发布阶段转换（如 title 转换器）后的结果，不由客户端提交
+	Title string `json:"-"`
+	// References 嵌套引用：该块内容中引用的块（可继续嵌套，超过 render.maxRefDepth 层的跳过）
+	References []BlockReferenceReq `json:"references,omitempty"`
 }
 
 // CreateShareResponse 创建分享响应
 type CreateShareResponse struct {
-	ShareID         string    `json:"shareId"`
-	ShareURL        string    `json:"shareUrl"`
-	DocID           string    `json:"docId"`
-	DocTitle        string    `json:"docTitle"`
-	RequirePassword bool      `json:"requirePassword"`
-	ExpireAt        time.Time `json:"expireAt"`
-	IsPublic        bool      `json:"isPublic"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
-	Reused          bool      `json:"reused"`
+	ShareID         string         `json:"shareId"`
+	ShareURL        string         `json:"shareUrl"`
+	DocID           string         `json:"docId"`
+	DocTitle        string         `json:"docTitle"`
+	TeamID          string         `json:"teamId,omitempty"`
+	RequirePassword bool           `json:"requirePassword"`
+	ExpireAt        time.Time      `json:"expireAt"`
+	IsPublic        bool           `json:"isPublic"`
+	AllowEmbed      bool           `json:"allowEmbed"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+	Reused          bool           `json:"reused"`
+	Report          *PublishReport `json:"report"` // 引用块发布结果
+	Scan            *ScanReport    `json:"scan"`   // 敏感信息检测结果，检测关闭时为 null
+	// Kramdown format 为 kramdown 时的转换结果
+	Kramdown *KramdownReport `json:"kramdown,omitempty"`
+}
+
+// PublishReport 引用块发布结果。发布在一个事务中完成：
+// 成功时 created/updated 为已写入的引用块片段；失败时不写入任何数据，failed 列出导致失败的引用
+type PublishReport struct {
+	Stripped int                `json:"stripped"` // 按 publish.hideAttribute 移除的块数（含子块）
+	Created  []PublishRefResult `json:"created"`
+	Updated  []PublishRefResult `json:"updated"`
+	Skipped  []PublishRefResult `json:"skipped"`
+	Failed   []PublishRefResult `json:"failed"`
+}
+
+// PublishRefResult 单个引用块的发布结果
+type PublishRefResult struct {
+	BlockID  string `json:"blockId"`
+	ShareID  string `json:"shareId,omitempty"` // 引用块片段 ID，公开地址为 /s/:id
+	ShareURL string `json:"shareUrl,omitempty"`
+	Reason   string `json:"reason,omitempty"` // 跳过或失败的原因
+}
+
+// 引用块跳过 / 失败原因
+const (
+	refReasonInvalidID = "invalid block id"
+	refReasonEmpty     = "empty content"
+	refReasonSelf      = "references the shared document itself"
+	refReasonDuplicate = "duplicate reference"
+	refReasonCyclic    = "cyclic reference"
+	refReasonTooDeep   = "exceeds max reference depth"
+	refReasonInDoc     = "block is in the shared document"
+	refReasonAnchor    = "invalid anchor"
+)
+
+// blockIDPattern 思源块 ID：14 位时间戳 + 7 位随机串
+var blockIDPattern = regexp.MustCompile(`^[0-9]{14,}-[0-9a-z]{7,}$`)
+
+// anchorPattern 页内锚点：字母、数字、- 与 _（与 rehype-slug 生成的标题 id 兼容），可直接写入 Markdown 链接
+var anchorPattern = regexp.MustCompile(`^[\p{L}\p{N}\p{M}_-]{1,128}$`)
+
+func newPublishReport() *PublishReport {
+	return &PublishReport{
+		Created: []PublishRefResult{},
+		Updated: []PublishRefResult{},
+		Skipped: []PublishRefResult{},
+		Failed:  []PublishRefResult{},
+	}
+}
+
+// TransferShareRequest 转移分享所有权请求（目标用户与目标团队二选一）
+type TransferShareRequest struct {
+	ToUserID   string `json:"toUserId"`
+	ToUsername string `json:"toUsername"`
+	ToTeamID   string `json:"toTeamId"`
 }
 
 // BatchDeleteShareRequest 批量关闭分享请求
@@ -78,7 +156,26 @@ func CreateShare(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	userIDStr := userID.(string)
 
-	existingShare, err := models.FindActiveShareByDoc(userIDStr, req.DocID)
+	req.TeamID = strings.TrimSpace(req.TeamID)
+	if req.TeamID != "" {
+		role, err := models.GetTeamRole(req.TeamID, userIDStr)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": 1,
+				"msg":  "Failed to query team: " + err.Error(),
+			})
+			return
+		}
+		if !models.CanEditTeamShares(role) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": 1,
+				"msg":  "Team editor role required",
+			})
+			return
+		}
+	}
+
+	existingShare, err := models.FindActiveShareByDoc(userIDStr, req.TeamID, req.DocID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 1,
@@ -113,6 +210,74 @@ func CreateShare(c *gin.Context) {
 		}
 	}
 
+	// 写入前移除不公开的块，再校验并展开全部引用块（含嵌套引用），存在无效引用时整体拒绝
+	report := newPublishReport()
+	report.Stripped = stripHiddenBlocks(&req)
+
+	// kramdown 格式在移除不公开的块（依赖 IAL）之后转换为 Markdown
+	var normalized *KramdownReport
+	if req.Format == formatKramdown {
+		normalized = normalizeKramdown(&req)
+	}
+
+	// 发布阶段转换（分享、用户、服务端默认的流水线依次生效），结果写入数据库并参与检测
+	sharePipeline := req.Pipeline
+	if sharePipeline == nil && existingShare != nil {
+		sharePipeline, _ = transform.Decode(existingShare.Pipeline)
+	}
+	chain, err := publishChain(reqDB(c), userIDStr, sharePipeline)
+	if err == nil && req.Pipeline != nil {
+		err = req.Pipeline.Validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 1,
+			"msg":  "Invalid pipeline: " + err.Error(),
+		})
+		return
+	}
+	shareID := ""
+	if existingShare != nil {
+		shareID = existingShare.ID
+	}
+	if err := runPublishTransformers(c.Request.Context(), chain, &req, shareID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 1,
+			"msg":  "Failed to transform content: " + err.Error(),
+		})
+		return
+	}
+
+	// 检测密钥与个人信息：block 模式拒绝发布，redact 模式替换为占位符后继续
+	scan, err := scanShareRequest(reqDB(c), userIDStr, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 1,
+			"msg":  "Failed to scan content: " + err.Error(),
+		})
+		return
+	}
+	if scan != nil && scan.Mode == config.ScanModeBlock && len(scan.Findings) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code": 1,
+			"msg":  "Sensitive content detected",
+			"data": gin.H{"scan": scan},
+		})
+		return
+	}
+
+	anchors := validateAnchors(req.Anchors, report)
+	submitted := append(append([]BlockReferenceReq{}, req.References...), embedsToPublish(req.References, req.Embeds)...)
+	refs := planReferences(req.DocID, req.Content, submitted, anchors, config.Get().Render.MaxRefDepth, report)
+	if len(report.Failed) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 1,
+			"msg":  "Invalid references",
+			"data": gin.H{"report": report},
+		})
+		return
+	}
+
 	var share *models.Share
 	reused := false
 	if existingShare != nil {
@@ -122,6 +287,7 @@ func CreateShare(c *gin.Context) {
 		share = &models.Share{
 			ID:     generateShareID(),
 			UserID: userIDStr,
+			TeamID: req.TeamID,
 			DocID:  req.DocID,
 		}
 	}
@@ -130,11 +296,16 @@ func CreateShare(c *gin.Context) {
 	share.Content = req.Content
 	share.RequirePassword = req.RequirePassword
 	share.IsPublic = req.IsPublic
+	share.AllowEmbed = req.AllowEmbed
 	share.ExpireAt = time.Now().AddDate(0, 0, req.ExpireDays)
 
-	// 处理引用块数据
-	if len(req.References) > 0 {
-		refsJSON, err := json.Marshal(req.References)
+	// 处理引用块数据：只保存文档直接引用与嵌入的块，嵌套引用由各片段记录
+	if len(submitted) > 0 {
+		top := make([]models.BlockReference, len(submitted))
+		for i, ref := range submitted {
+			top[i] = models.BlockReference{BlockID: ref.BlockID, Content: ref.Content, DisplayText: ref.DisplayText, RefCount: ref.RefCount}
+		}
+		refsJSON, err := json.Marshal(top)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"code": 1,
@@ -147,6 +318,16 @@ func CreateShare(c *gin.Context) {
 		share.References = ""
 	}
 
+	if req.Pipeline != nil {
+		share.Pipeline = transform.Encode(req.Pipeline)
+	}
+
+	share.Anchors = ""
+	if len(anchors) > 0 {
+		anchorsJSON, _ := json.Marshal(anchors)
+		share.Anchors = string(anchorsJSON)
+	}
+
 	if req.RequirePassword {
 		if password != "" {
 			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -164,82 +345,111 @@ func CreateShare(c *gin.Context) {
 		share.PasswordHash = ""
 	}
 
-	if reused {
-		if err := models.DB.Save(share).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code": 1,
-				"msg":  "Failed to update share: " + err.Error(),
-			})
-			return
-		}
-	} else {
-		if err := models.DB.Create(share).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code": 1,
-				"msg":  "Failed to create share: " + err.Error(),
-			})
-			return
+	// 父分享与全部引用块子分享在同一事务中写入，任一失败则整体回滚
+	var created, updated []PublishRefResult
+	var failedRef *PublishRefResult
+	var affected []string
+	err = reqDB(c).Transaction(func(tx *gorm.DB) error {
+		if reused {
+			if err := tx.Save(share).Error; err != nil {
+				return fmt.Errorf("update share: %w", err)
+			}
+		} else {
+			if err := tx.Create(share).Error; err != nil {
+				return fmt.Errorf("create share: %w", err)
+			}
 		}
-	}
 
-	// 构建分享 URL（双轨：自动推断 + 可被 X-Base-URL 覆盖）
-	baseURL := c.GetHeader("X-Base-URL")
-	if baseURL == "" {
-		// 优先使用代理头
-		proto := c.GetHeader("X-Forwarded-Proto")
-		host := c.GetHeader("X-Forwarded-Host")
-		if proto == "" {
-			if c.Request.TLS != nil {
-				proto = "https"
-			} else {
-				proto = "http"
-			}
+		blockIDs := make([]string, len(refs))
+		for i, ref := range refs {
+			blockIDs[i] = ref.BlockID
 		}
-		if host == "" {
-			host = c.Request.Host
+		// 所有者已有的引用块片段（可能同时被其他父分享引用）
+		existingSnippets, err := models.FindSnippetsByBlocks(tx, share.UserID, share.TeamID, blockIDs)
+		if err != nil {
+			return fmt.Errorf("query block snippets: %w", err)
 		}
-		// 移除可能存在的尾部斜杠
-		baseURL = proto + "://" + strings.TrimSuffix(host, "/")
-	}
-	shareURL := strings.TrimSuffix(baseURL, "/") + "/s/" + share.ID
-
-	// 为引用块创建子分享
-	if len(req.References) > 0 {
-		for _, ref := range req.References {
-			// 检查是否已存在该块的分享(通过 docId = blockId 查找)
-			existingBlockShare, _ := models.FindActiveShareByDoc(userIDStr, ref.BlockID)
-
-			// 生成引用块标题
-			blockTitle := generateBlockTitle(ref)
 
-			var blockShare *models.Share
-			if existingBlockShare != nil && !existingBlockShare.IsExpired() {
-				// 更新已有的块分享
-				blockShare = existingBlockShare
-				blockShare.DocTitle = blockTitle
-				blockShare.Content = ref.Content
-				blockShare.ExpireAt = share.ExpireAt
-				blockShare.ParentShareID = share.ID
-				models.DB.Save(blockShare)
-			} else {
-				// 创建新的块分享
-				blockShare = &models.Share{
-					ID:            generateShareID(),
-					UserID:        userIDStr,
-					DocID:         ref.BlockID, // 使用 blockId 作为 docId
-					DocTitle:      blockTitle,
-					Content:       ref.Content,
-					ParentShareID: share.ID,
-					// 继承父分享的密码和过期时间
-					RequirePassword: share.RequirePassword,
-					PasswordHash:    share.PasswordHash,
-					ExpireAt:        share.ExpireAt,
-					IsPublic:        share.IsPublic,
+		// 创建或更新引用块片段，并记录引用（含嵌套引用及其层数）
+		snippetUser, snippetTeam := models.SnippetOwner(share.UserID, share.TeamID)
+		byID := make(map[string]*publishRef, len(refs))
+		for _, ref := range refs {
+			byID[ref.BlockID] = ref
+		}
+		refRows := make([]models.ShareRef, 0, len(refs))
+		for _, ref := range refs {
+			blockTitle := cmp.Or(strings.TrimSpace(ref.Title), strings.TrimSpace(ref.DisplayText), "引用块")
+			nested := ref.referencesJSON(byID)
+
+			if snippet := existingSnippets[ref.BlockID]; snippet != nil {
+				// 更新已有的片段
+				snippet.Title = blockTitle
+				snippet.Content = ref.Content
+				snippet.References = nested
+				if err := tx.Save(snippet).Error; err != nil {
+					failedRef = &PublishRefResult{BlockID: ref.BlockID, ShareID: snippet.ID, Reason: err.Error()}
+					return fmt.Errorf("update block snippet %s: %w", ref.BlockID, err)
 				}
-				models.DB.Create(blockShare)
+				updated = append(updated, PublishRefResult{BlockID: ref.BlockID, ShareID: snippet.ID})
+				refRows = append(refRows, models.ShareRef{BlockID: ref.BlockID, SnippetID: snippet.ID, Depth: ref.depth})
+				continue
 			}
+
+			// 创建新的片段；访问条件由引用它的父分享决定
+			snippet := &models.BlockSnippet{
+				ID:         generateShareID(),
+				UserID:     snippetUser,
+				TeamID:     snippetTeam,
+				BlockID:    ref.BlockID,
+				Title:      blockTitle,
+				Content:    ref.Content,
+				References: nested,
+			}
+			if err := tx.Create(snippet).Error; err != nil {
+				failedRef = &PublishRefResult{BlockID: ref.BlockID, Reason: err.Error()}
+				return fmt.Errorf("create block snippet %s: %w", ref.BlockID, err)
+			}
+			created = append(created, PublishRefResult{BlockID: ref.BlockID, ShareID: snippet.ID})
+			refRows = append(refRows, models.ShareRef{BlockID: ref.BlockID, SnippetID: snippet.ID, Depth: ref.depth})
+		}
+
+		affected, err = models.ReplaceShareRefs(tx, share.ID, refRows)
+		if err != nil {
+			return fmt.Errorf("index references: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		// 事务已回滚，没有任何引用块被写入
+		if failedRef != nil {
+			report.Failed = append(report.Failed, *failedRef)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 1,
+			"msg":  "Failed to publish share, no changes were saved: " + err.Error(),
+			"data": gin.H{"report": report},
+		})
+		return
+	}
+
+	// 父分享的密码、过期时间变化影响其引用的全部片段（包括本次不再引用的）
+	InvalidateShares(affected...)
+	if reused {
+		InvalidateShares(share.ID)
+		metrics.ShareUpdated()
+	} else {
+		metrics.ShareCreated()
+	}
+	for _, r := range created {
+		r.ShareURL = ShareURL(c, r.ShareID)
+		report.Created = append(report.Created, r)
 	}
+	for _, r := range updated {
+		r.ShareURL = ShareURL(c, r.ShareID)
+		report.Updated = append(report.Updated, r)
+	}
+
+	shareURL := ShareURL(c, share.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
@@ -249,19 +459,36 @@ func CreateShare(c *gin.Context) {
 			ShareURL:        shareURL,
 			DocID:           share.DocID,
 			DocTitle:        share.DocTitle,
+			TeamID:          share.TeamID,
 			RequirePassword: share.RequirePassword,
 			ExpireAt:        share.ExpireAt,
 			IsPublic:        share.IsPublic,
+			AllowEmbed:      share.AllowEmbed,
 			CreatedAt:       share.CreatedAt,
 			UpdatedAt:       share.UpdatedAt,
 			Reused:          reused,
+			Report:          report,
+			Scan:            scan,
+			Kramdown:        normalized,
 		},
 	})
 }
 
-// ListShares 获取用户的分享列表
+// ListShares 获取用户的分享列表（指定 teamId 时返回该团队的分享）
 func ListShares(c *gin.Context) {
-	userID, _ := c.Get("userID")
+	userID := c.GetString("userID")
+	teamID := strings.TrimSpace(c.Query("teamId"))
+	if teamID != "" {
+		role, err := models.GetTeamRole(teamID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query team: " + err.Error()})
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Not a member of this team"})
+			return
+		}
+	}
 
 	// 分页参数
 	page := 1
@@ -282,13 +509,13 @@ func ListShares(c *gin.Context) {
 	offset := (page - 1) * size
 
 	var total int64
-	if err := models.DB.Model(&models.Share{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+	if err := reqDB(c).Model(&models.Share{}).Scopes(models.OwnedBy(userID, teamID)).Count(&total).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to count shares: " + err.Error()})
 		return
 	}
 
 	var shares []models.Share
-	if err := models.DB.Where("user_id = ?", userID).
+	if err := reqDB(c).Scopes(models.OwnedBy(userID, teamID)).
 		Order("created_at DESC").
 		Offset(offset).Limit(size).
 		Find(&shares).Error; err != nil {
@@ -300,31 +527,18 @@ func ListShares(c *gin.Context) {
 	}
 
 	// 返回轻量结构并附带 shareUrl
-	baseURL := c.GetHeader("X-Base-URL")
-	if baseURL == "" {
-		proto := c.GetHeader("X-Forwarded-Proto")
-		host := c.GetHeader("X-Forwarded-Host")
-		if proto == "" {
-			if c.Request.TLS != nil {
-				proto = "https"
-			} else {
-				proto = "http"
-			}
-		}
-		if host == "" {
-			host = c.Request.Host
-		}
-		baseURL = proto + "://" + strings.TrimSuffix(host, "/")
-	}
-	baseURL = strings.TrimSuffix(baseURL, "/")
+	baseURL := BaseURL(c)
 
 	type item struct {
 		ID              string    `json:"id"`
 		DocID           string    `json:"docId"`
 		DocTitle        string    `json:"docTitle"`
+		TeamID          string    `json:"teamId,omitempty"`
+		UserID          string    `json:"userId"`
 		RequirePassword bool      `json:"requirePassword"`
 		ExpireAt        time.Time `json:"expireAt"`
 		IsPublic        bool      `json:"isPublic"`
+		AllowEmbed      bool      `json:"allowEmbed"`
 		ViewCount       int       `json:"viewCount"`
 		CreatedAt       time.Time `json:"createdAt"`
 		ShareURL        string    `json:"shareUrl"`
@@ -335,12 +549,15 @@ func ListShares(c *gin.Context) {
 			ID:              s.ID,
 			DocID:           s.DocID,
 			DocTitle:        s.DocTitle,
+			TeamID:          s.TeamID,
+			UserID:          s.UserID,
 			RequirePassword: s.RequirePassword,
 			ExpireAt:        s.ExpireAt,
 			IsPublic:        s.IsPublic,
+			AllowEmbed:      s.AllowEmbed,
 			ViewCount:       s.ViewCount,
 			CreatedAt:       s.CreatedAt,
-			ShareURL:        baseURL + "/s/" + s.ID,
+			ShareURL:        shareURLFrom(baseURL, s.ID),
 		})
 	}
 
@@ -356,27 +573,29 @@ func ListShares(c *gin.Context) {
 	})
 }
 
-// DeleteShare 删除分享
+// DeleteShare 删除分享（个人分享限本人，团队分享限团队所有者与编辑者）
 func DeleteShare(c *gin.Context) {
 	shareID := c.Param("id")
-	userID, _ := c.Get("userID")
+	userID := c.GetString("userID")
 
-	result := models.DB.Where("id = ? AND user_id = ?", shareID, userID).Delete(&models.Share{})
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	share, status, err := loadEditableShare(shareID, userID)
+	if err != nil {
+		c.JSON(status, gin.H{
 			"code": 1,
-			"msg":  "Failed to delete share: " + result.Error.Error(),
+			"msg":  err.Error(),
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
+	affected, err := models.DeleteShares(reqDB(c), share.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 1,
-			"msg":  "Share not found or unauthorized",
+			"msg":  "Failed to delete share: " + err.Error(),
 		})
 		return
 	}
+	InvalidateShares(append(affected, share.ID)...)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
@@ -384,6 +603,119 @@ func DeleteShare(c *gin.Context) {
 	})
 }
 
+// TransferShare 转移分享所有权：个人分享由本人转出，团队分享须团队所有者转出；
+// 转入团队须具备目标团队编辑权限。父分享改为引用新所有者的引用块片段，其他父分享的引用不受影响。
+func TransferShare(c *gin.Context) {
+	var req TransferShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	req.ToUserID = strings.TrimSpace(req.ToUserID)
+	req.ToUsername = strings.TrimSpace(req.ToUsername)
+	req.ToTeamID = strings.TrimSpace(req.ToTeamID)
+	toUser := req.ToUserID != "" || req.ToUsername != ""
+	if toUser == (req.ToTeamID != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Specify exactly one of toUserId/toUsername or toTeamId"})
+		return
+	}
+
+	userID := c.GetString("userID")
+	var share models.Share
+	if err := reqDB(c).Where("id = ?", c.Param("id")).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found or unauthorized"})
+		return
+	}
+
+	// 转出权限
+	if share.TeamID == "" {
+		if share.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found or unauthorized"})
+			return
+		}
+	} else {
+		role, err := models.GetTeamRole(share.TeamID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query team: " + err.Error()})
+			return
+		}
+		if role != models.TeamRoleOwner {
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Team owner role required"})
+			return
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if toUser {
+		var target models.User
+		q := reqDB(c).Where("is_active = ?", true)
+		if req.ToUserID != "" {
+			q = q.Where("id = ?", req.ToUserID)
+		} else {
+			q = q.Where("username = ?", req.ToUsername)
+		}
+		if err := q.First(&target).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Target user not found"})
+			return
+		}
+		updates["user_id"] = target.ID
+		updates["team_id"] = ""
+	} else {
+		role, err := models.GetTeamRole(req.ToTeamID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query team: " + err.Error()})
+			return
+		}
+		if !models.CanEditTeamShares(role) {
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Team editor role required on target team"})
+			return
+		}
+		updates["team_id"] = req.ToTeamID
+	}
+
+	err := reqDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Share{}).Where("id = ?", share.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", share.ID).First(&share).Error; err != nil {
+			return err
+		}
+		_, err := models.MoveShareSnippets(tx, &share, generateShareID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to transfer share: " + err.Error()})
+		return
+	}
+	// 所有者变化影响父分享及其引用块片段的引用解析
+	PurgeShareCache()
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"id":     share.ID,
+		"userId": share.UserID,
+		"teamId": share.TeamID,
+	}})
+}
+
+// loadEditableShare 加载当前用户可编辑的分享，失败时返回对应的 HTTP 状态码
+func loadEditableShare(shareID, userID string) (*models.Share, int, error) {
+	var share models.Share
+	if err := models.DB.Where("id = ?", shareID).First(&share).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, http.StatusNotFound, errors.New("Share not found or unauthorized")
+		}
+		return nil, http.StatusInternalServerError, errors.New("Failed to query share: " + err.Error())
+	}
+	ok, err := models.CanEditShare(userID, &share)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Failed to query team: " + err.Error())
+	}
+	if !ok {
+		return nil, http.StatusNotFound, errors.New("Share not found or unauthorized")
+	}
+	return &share, http.StatusOK, nil
+}
+
 // DeleteSharesBatch 批量关闭分享
 func DeleteSharesBatch(c *gin.Context) {
 	var req BatchDeleteShareRequest
@@ -397,7 +729,7 @@ func DeleteSharesBatch(c *gin.Context) {
 
 	userID := c.GetString("userID")
 
-	// 如果没有指定分享 ID，则删除当前用户全部分享
+	// 如果没有指定分享 ID，则删除当前用户全部个人分享（团队分享需逐个指定）
 	if len(req.ShareIDs) == 0 {
 		count, err := models.DeleteSharesByUser(userID)
 		if err != nil {
@@ -407,6 +739,7 @@ func DeleteSharesBatch(c *gin.Context) {
 			})
 			return
 		}
+		PurgeShareCache()
 
 		c.JSON(http.StatusOK, gin.H{
 			"code": 0,
@@ -430,17 +763,24 @@ func DeleteSharesBatch(c *gin.Context) {
 			continue
 		}
 
-		result := models.DB.Where("id = ? AND user_id = ?", shareID, userID).Delete(&models.Share{})
-		if result.Error != nil {
-			failed[shareID] = result.Error.Error()
+		share, status, err := loadEditableShare(shareID, userID)
+		if err != nil {
+			if status == http.StatusNotFound {
+				response.NotFound = append(response.NotFound, shareID)
+			} else {
+				failed[shareID] = err.Error()
+			}
 			continue
 		}
-		if result.RowsAffected == 0 {
-			response.NotFound = append(response.NotFound, shareID)
+		affected, err := models.DeleteShares(reqDB(c), share.ID)
+		if err != nil {
+			failed[shareID] = err.Error()
 			continue
 		}
+		InvalidateShares(affected...)
 		response.Deleted = append(response.Deleted, shareID)
 	}
+	InvalidateShares(response.Deleted...)
 
 	if len(failed) > 0 {
 		response.Failed = failed
@@ -459,43 +799,3 @@ func generateShareID() string {
 	rand.Read(b)
 	return hex.EncodeToString(b)
 }
-
-// generateBlockTitle 生成引用块的标题
-func generateBlockTitle(ref BlockReferenceReq) string {
-	// 优先使用显示文本
-	if ref.DisplayText != "" {
-		return ref.DisplayText
-	}
-
-	// 使用内容的第一行作为标题
-	if ref.Content != "" {
-		lines := strings.Split(ref.Content, "\n")
-		for _, line := range lines {
-			trimmed := strings.TrimSpace(line)
-			// 跳过空行和 Markdown 标记
-			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-				// 限制标题长度
-				if len(trimmed) > 50 {
-					return trimmed[:50] + "..."
-				}
-				return trimmed
-			}
-		}
-
-		// 如果所有行都是标题或空行,使用第一个非空行
-		for _, line := range lines {
-			trimmed := strings.TrimSpace(line)
-			if trimmed != "" {
-				// 移除 Markdown 标题标记
-				trimmed = strings.TrimLeft(trimmed, "# ")
-				if len(trimmed) > 50 {
-					return trimmed[:50] + "..."
-				}
-				return trimmed
-			}
-		}
-	}
-
-	// 降级使用 blockId
-	return "引用块"
-}
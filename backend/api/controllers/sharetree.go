@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ShareTreeNode 分享引用树节点：根节点为分享，子节点为其引用的引用块片段，片段的子节点为其嵌套引用的片段
+type ShareTreeNode struct {
+	ID              string            `json:"id"`
+	Kind            string            `json:"kind"` // share / snippet
+	DocID           string            `json:"docId,omitempty"`
+	BlockID         string            `json:"blockId,omitempty"`
+	Title           string            `json:"title"`
+	ShareURL        string            `json:"shareUrl"`
+	Status          string            `json:"status"` // active / expired
+	RequirePassword bool              `json:"requirePassword"`
+	ExpireAt        time.Time         `json:"expireAt"`
+	ViewCount       int               `json:"viewCount"`
+	Parents         []ShareTreeParent `json:"parents,omitempty"` // 片段：引用它的全部父分享，即访问条件的来源
+	Children        []ShareTreeNode   `json:"children"`
+	// Cycle 片段已出现在从根到该节点的路径上（循环引用），不再展开子节点
+	Cycle bool `json:"cycle,omitempty"`
+}
+
+// ShareTreeParent 引用片段的父分享
+type ShareTreeParent struct {
+	ID              string    `json:"id"`
+	DocTitle        string    `json:"docTitle"`
+	Status          string    `json:"status"`
+	RequirePassword bool      `json:"requirePassword"`
+	ExpireAt        time.Time `json:"expireAt"`
+}
+
+// 引用树节点类型
+const (
+	treeKindShare   = "share"
+	treeKindSnippet = "snippet"
+)
+
+// GetShareTree 列出分享及其引用块片段的状态（个人分享限本人，团队分享限团队成员）
+func GetShareTree(c *gin.Context) {
+	userID := c.GetString("userID")
+	var share models.Share
+	if err := reqDB(c).Where("id = ?", c.Param("id")).First(&share).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found or unauthorized"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query share: " + err.Error()})
+		return
+	}
+	ok, err := models.CanViewShare(userID, &share)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query team: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found or unauthorized"})
+		return
+	}
+
+	now := time.Now()
+	root := ShareTreeNode{
+		ID:              share.ID,
+		Kind:            treeKindShare,
+		DocID:           share.DocID,
+		Title:           share.DocTitle,
+		ShareURL:        ShareURL(c, share.ID),
+		Status:          shareStatus(&share, now),
+		RequirePassword: share.RequirePassword,
+		ExpireAt:        share.ExpireAt,
+		ViewCount:       share.ViewCount + views.pendingFor(share.ID),
+		Children:        []ShareTreeNode{},
+	}
+
+	ctx := c.Request.Context()
+	snippets, err := models.SnippetsOfShare(ctx, share.ID)
+	if err == nil {
+		var refs []models.ShareRef
+		if refs, err = models.ShareRefsOf(ctx, share.ID); err == nil {
+			ids := make([]string, len(snippets))
+			for i, s := range snippets {
+				ids[i] = s.ID
+			}
+			var parents map[string][]models.Share
+			if parents, err = models.SnippetParents(ctx, ids...); err == nil {
+				root.Children = buildSnippetTree(c, snippets, refs, parents, now)
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query references: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": root})
+}
+
+// buildSnippetTree 按引用关系展开父分享的片段：第 1 层为文档直接引用的片段，
+// 其下为片段内容引用的片段（片段 references），最多 render.maxRefDepth 层，循环引用标记 cycle 后停止展开
+func buildSnippetTree(c *gin.Context, snippets []models.BlockSnippet, refs []models.ShareRef, parents map[string][]models.Share, now time.Time) []ShareTreeNode {
+	byBlock := make(map[string]*models.BlockSnippet, len(snippets))
+	byID := make(map[string]*models.BlockSnippet, len(snippets))
+	for i := range snippets {
+		byBlock[snippets[i].BlockID] = &snippets[i]
+		byID[snippets[i].ID] = &snippets[i]
+	}
+	maxDepth := config.Get().Render.MaxRefDepth
+	path := map[string]bool{}
+
+	var node func(s *models.BlockSnippet, depth int) ShareTreeNode
+	node = func(s *models.BlockSnippet, depth int) ShareTreeNode {
+		access := snippetAccessOf(parents[s.ID], now)
+		n := ShareTreeNode{
+			ID:              s.ID,
+			Kind:            treeKindSnippet,
+			BlockID:         s.BlockID,
+			Title:           s.Title,
+			ShareURL:        ShareURL(c, s.ID),
+			Status:          access.status,
+			RequirePassword: access.requirePassword,
+			ExpireAt:        access.expireAt,
+			ViewCount:       s.ViewCount + views.pendingFor(s.ID),
+			Parents:         make([]ShareTreeParent, 0, len(parents[s.ID])),
+			Children:        []ShareTreeNode{},
+		}
+		for _, p := range parents[s.ID] {
+			n.Parents = append(n.Parents, ShareTreeParent{
+				ID:              p.ID,
+				DocTitle:        p.DocTitle,
+				Status:          shareStatus(&p, now),
+				RequirePassword: p.RequirePassword,
+				ExpireAt:        p.ExpireAt,
+			})
+		}
+		if path[s.ID] {
+			n.Cycle = true
+			return n
+		}
+		if depth >= maxDepth || s.References == "" {
+			return n
+		}
+		var nested []models.BlockReference
+		if json.Unmarshal([]byte(s.References), &nested) != nil {
+			return n
+		}
+		path[s.ID] = true
+		for _, ref := range nested {
+			if child := byBlock[ref.BlockID]; child != nil {
+				n.Children = append(n.Children, node(child, depth+1))
+			}
+		}
+		delete(path, s.ID)
+		return n
+	}
+
+	children := []ShareTreeNode{}
+	for _, r := range refs {
+		if s := byID[r.SnippetID]; s != nil && r.Depth == 1 {
+			children = append(children, node(s, 1))
+		}
+	}
+	return children
+}
+
+// shareStatus 分享在 now 时的状态
+func shareStatus(s *models.Share, now time.Time) string {
+	if now.Before(s.ExpireAt) {
+		return shareStatusActive
+	}
+	return shareStatusExpired
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateTeamRequest 创建团队请求
+type CreateTeamRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=100"`
+}
+
+// AddTeamMemberRequest 添加团队成员请求（userId 与 username 二选一）
+type AddTeamMemberRequest struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// UpdateTeamMemberRequest 修改成员角色请求
+type UpdateTeamMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+var (
+	errLastOwner     = errors.New("team must keep at least one owner")
+	errTeamHasShares = errors.New("team still owns shares, transfer or delete them first")
+)
+
+// CreateTeam 创建团队，创建者成为所有者
+func CreateTeam(c *gin.Context) {
+	var req CreateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	userID := c.GetString("userID")
+	team := &models.Team{ID: "team_" + randHex(12), Name: strings.TrimSpace(req.Name), CreatedBy: userID}
+	err := reqDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(team).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TeamMember{TeamID: team.ID, UserID: userID, Role: models.TeamRoleOwner}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to create team: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"id": team.ID, "name": team.Name, "role": models.TeamRoleOwner, "createdAt": team.CreatedAt,
+	}})
+}
+
+// ListTeams 列出当前用户所在的团队及其角色
+func ListTeams(c *gin.Context) {
+	userID := c.GetString("userID")
+	type row struct {
+		ID        string
+		Name      string
+		Role      string
+		CreatedBy string
+	}
+	var rows []row
+	if err := reqDB(c).Table("teams").
+		Select("teams.id, teams.name, teams.created_by, team_members.role").
+		Joins("JOIN team_members ON team_members.team_id = teams.id").
+		Where("team_members.user_id = ? AND teams.deleted_at IS NULL", userID).
+		Order("teams.created_at DESC").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list teams: " + err.Error()})
+		return
+	}
+	items := make([]gin.H, 0, len(rows))
+	for _, r := range rows {
+		items = append(items, gin.H{"id": r.ID, "name": r.Name, "role": r.Role, "createdBy": r.CreatedBy})
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": items}})
+}
+
+// GetTeam 团队详情与成员列表（成员可见）
+func GetTeam(c *gin.Context) {
+	team, role, ok := loadTeamForMember(c, "")
+	if !ok {
+		return
+	}
+	type member struct {
+		UserID   string `json:"userId"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Role     string `json:"role"`
+	}
+	var members []member
+	if err := reqDB(c).Table("team_members").
+		Select("team_members.user_id, users.username, users.email, team_members.role").
+		Joins("JOIN users ON users.id = team_members.user_id").
+		Where("team_members.team_id = ?", team.ID).
+		Order("team_members.created_at ASC").
+		Scan(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list members: " + err.Error()})
+		return
+	}
+	var shareCount int64
+	reqDB(c).Model(&models.Share{}).Where("team_id = ?", team.ID).Count(&shareCount)
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"id": team.ID, "name": team.Name, "role": role, "createdBy": team.CreatedBy, "createdAt": team.CreatedAt,
+		"members": members, "shareCount": shareCount,
+	}})
+}
+
+// UpdateTeam 修改团队名称（所有者）
+func UpdateTeam(c *gin.Context) {
+	var req CreateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	team, _, ok := loadTeamForMember(c, models.TeamRoleOwner)
+	if !ok {
+		return
+	}
+	if err := reqDB(c).Model(team).Update("name", strings.TrimSpace(req.Name)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to update team: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
+
+// DeleteTeam 删除团队（所有者）；团队仍拥有分享时拒绝，需先转移或删除
+func DeleteTeam(c *gin.Context) {
+	team, _, ok := loadTeamForMember(c, models.TeamRoleOwner)
+	if !ok {
+		return
+	}
+	// 分享数在事务内复查，避免与并发的分享发布或转移竞争；团队片段与残留引用同事务删除
+	var affected []string
+	err := reqDB(c).Transaction(func(tx *gorm.DB) error {
+		var shareCount int64
+		if err := tx.Model(&models.Share{}).Where("team_id = ?", team.ID).Count(&shareCount).Error; err != nil {
+			return err
+		}
+		if shareCount > 0 {
+			return errTeamHasShares
+		}
+		var err error
+		if affected, err = models.DeleteTeamSnippets(tx, team.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", team.ID).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(team).Error
+	})
+	switch {
+	case errors.Is(err, errTeamHasShares):
+		c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": "Team still owns shares, transfer or delete them first"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to delete team: " + err.Error()})
+		return
+	}
+	InvalidateShares(affected...)
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
+
+// AddTeamMember 添加成员（所有者）
+func AddTeamMember(c *gin.Context) {
+	var req AddTeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if !models.ValidTeamRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid role"})
+		return
+	}
+	team, _, ok := loadTeamForMember(c, models.TeamRoleOwner)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	q := reqDB(c).Where("is_active = ?", true)
+	switch {
+	case strings.TrimSpace(req.UserID) != "":
+		q = q.Where("id = ?", strings.TrimSpace(req.UserID))
+	case strings.TrimSpace(req.Username) != "":
+		q = q.Where("username = ?", strings.TrimSpace(req.Username))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "userId or username required"})
+		return
+	}
+	if err := q.First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "User not found"})
+		return
+	}
+
+	var count int64
+	reqDB(c).Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", team.ID, user.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": "User is already a member"})
+		return
+	}
+	if err := reqDB(c).Create(&models.TeamMember{TeamID: team.ID, UserID: user.ID, Role: req.Role}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to add member: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"userId": user.ID, "username": user.Username, "role": req.Role,
+	}})
+}
+
+// UpdateTeamMember 修改成员角色（所有者），团队至少保留一名所有者
+func UpdateTeamMember(c *gin.Context) {
+	var req UpdateTeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if !models.ValidTeamRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid role"})
+		return
+	}
+	team, _, ok := loadTeamForMember(c, models.TeamRoleOwner)
+	if !ok {
+		return
+	}
+	memberID := c.Param("userId")
+	err := reqDB(c).Transaction(func(tx *gorm.DB) error {
+		var m models.TeamMember
+		if err := tx.Where("team_id = ? AND user_id = ?", team.ID, memberID).First(&m).Error; err != nil {
+			return err
+		}
+		if m.Role == models.TeamRoleOwner && req.Role != models.TeamRoleOwner {
+			n, err := models.CountTeamOwners(tx, team.ID)
+			if err != nil {
+				return err
+			}
+			if n <= 1 {
+				return errLastOwner
+			}
+		}
+		return tx.Model(&m).Where("team_id = ? AND user_id = ?", team.ID, memberID).Update("role", req.Role).Error
+	})
+	respondMemberChange(c, err)
+}
+
+// RemoveTeamMember 移除成员（所有者）或成员自行退出，团队至少保留一名所有者
+func RemoveTeamMember(c *gin.Context) {
+	memberID := c.Param("userId")
+	required := models.TeamRoleOwner
+	if memberID == c.GetString("userID") {
+		required = "" // 成员可自行退出
+	}
+	team, _, ok := loadTeamForMember(c, required)
+	if !ok {
+		return
+	}
+	err := reqDB(c).Transaction(func(tx *gorm.DB) error {
+		var m models.TeamMember
+		if err := tx.Where("team_id = ? AND user_id = ?", team.ID, memberID).First(&m).Error; err != nil {
+			return err
+		}
+		if m.Role == models.TeamRoleOwner {
+			n, err := models.CountTeamOwners(tx, team.ID)
+			if err != nil {
+				return err
+			}
+			if n <= 1 {
+				return errLastOwner
+			}
+		}
+		return tx.Where("team_id = ? AND user_id = ?", team.ID, memberID).Delete(&models.TeamMember{}).Error
+	})
+	respondMemberChange(c, err)
+}
+
+func respondMemberChange(c *gin.Context, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Member not found"})
+	case errors.Is(err, errLastOwner):
+		c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": "Team must keep at least one owner"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to update member: " + err.Error()})
+	}
+}
+
+// loadTeamForMember 加载 :id 指定的团队并校验当前用户角色（required 为空时任意成员均可），失败时已写出响应
+func loadTeamForMember(c *gin.Context, required string) (*models.Team, string, bool) {
+	var team models.Team
+	if err := reqDB(c).Where("id = ?", c.Param("id")).First(&team).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Team not found"})
+		return nil, "", false
+	}
+	role, err := models.GetTeamRole(team.ID, c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to query team: " + err.Error()})
+		return nil, "", false
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Team not found"})
+		return nil, "", false
+	}
+	if required != "" && role != required {
+		c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Team " + required + " role required"})
+		return nil, "", false
+	}
+	return &team, role, true
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// deleteTeam 以 userID 身份调用 DeleteTeam，返回状态码与响应
+func deleteTeam(t *testing.T, teamID, userID string) (int, gin.H) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/api/teams/"+teamID, nil)
+	c.Params = gin.Params{{Key: "id", Value: teamID}}
+	c.Set("userID", userID)
+	DeleteTeam(c)
+	var body gin.H
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, body
+}
+
+func TestDeleteTeam(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	db := models.DB
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(db.Create(&models.Team{ID: "team_a", Name: "A", CreatedBy: "u1"}).Error)
+	must(db.Create(&models.TeamMember{TeamID: "team_a", UserID: "u1", Role: models.TeamRoleOwner}).Error)
+	// 团队片段与指向它的残留引用（父分享已删除），以及同一块的个人片段
+	must(db.Create(&models.BlockSnippet{ID: "sn_team", TeamID: "team_a", BlockID: "20240101120000-abcdefg"}).Error)
+	must(db.Create(&models.BlockSnippet{ID: "sn_user", UserID: "u1", BlockID: "20240101120000-abcdefg"}).Error)
+	must(db.Create(&models.ShareRef{ParentShareID: "gone", BlockID: "20240101120000-abcdefg", SnippetID: "sn_team"}).Error)
+	must(db.Create(&models.Share{ID: "sh_team", UserID: "u1", TeamID: "team_a", DocID: "20240101120000-doc0001"}).Error)
+
+	if code, body := deleteTeam(t, "team_a", "u1"); code != http.StatusConflict {
+		t.Fatalf("with shares: status %d, body %v, want 409", code, body)
+	}
+	must(db.Delete(&models.Share{ID: "sh_team"}).Error)
+	if code, body := deleteTeam(t, "team_a", "u1"); code != http.StatusOK {
+		t.Fatalf("status %d, body %v, want 200", code, body)
+	}
+
+	count := func(model any, where string, args ...any) int64 {
+		t.Helper()
+		var n int64
+		must(db.Model(model).Where(where, args...).Count(&n).Error)
+		return n
+	}
+	if n := count(&models.BlockSnippet{}, "team_id = ?", "team_a"); n != 0 {
+		t.Errorf("team snippets left: %d", n)
+	}
+	if n := count(&models.ShareRef{}, "snippet_id = ?", "sn_team"); n != 0 {
+		t.Errorf("refs to team snippets left: %d", n)
+	}
+	if n := count(&models.TeamMember{}, "team_id = ?", "team_a"); n != 0 {
+		t.Errorf("team members left: %d", n)
+	}
+	if n := count(&models.BlockSnippet{}, "id = ?", "sn_user"); n != 1 {
+		t.Error("personal snippet was deleted")
+	}
+}
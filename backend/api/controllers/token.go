@@ -18,7 +18,7 @@ type CreateTokenRequest struct {
 func ListTokens(c *gin.Context) {
 	userID := c.GetString("userID")
 	var tokens []models.UserToken
-	if err := models.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+	if err := reqDB(c).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list tokens: " + err.Error()})
 		return
 	}
@@ -48,7 +48,7 @@ func CreateToken(c *gin.Context) {
 		Name:      req.Name,
 		TokenHash: hash,
 	}
-	if err := models.DB.Create(ut).Error; err != nil {
+	if err := reqDB(c).Create(ut).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to save token: " + err.Error()})
 		return
 	}
@@ -63,14 +63,14 @@ func RefreshToken(c *gin.Context) {
 	userID := c.GetString("userID")
 	id := c.Param("id")
 	var ut models.UserToken
-	if err := models.DB.Where("id = ? AND user_id = ? AND revoked = ?", id, userID, false).First(&ut).Error; err != nil {
+	if err := reqDB(c).Where("id = ? AND user_id = ? AND revoked = ?", id, userID, false).First(&ut).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Token not found"})
 		return
 	}
 	raw := randomToken(32)
 	hash := hashToken(raw)
 	ut.TokenHash = hash
-	if err := models.DB.Save(&ut).Error; err != nil {
+	if err := reqDB(c).Save(&ut).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to refresh token: " + err.Error()})
 		return
 	}
@@ -81,7 +81,7 @@ func RefreshToken(c *gin.Context) {
 func RevokeToken(c *gin.Context) {
 	userID := c.GetString("userID")
 	id := c.Param("id")
-	result := models.DB.Model(&models.UserToken{}).Where("id = ? AND user_id = ? AND revoked = ?", id, userID, false).Update("revoked", true)
+	result := reqDB(c).Model(&models.UserToken{}).Where("id = ? AND user_id = ? AND revoked = ?", id, userID, false).Update("revoked", true)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to revoke token: " + result.Error.Error()})
 		return
@@ -0,0 +1,534 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/totp"
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 挑战令牌类型：密码校验通过后签发，只能用于完成双因素认证，不能访问其它接口
+const (
+	challengeTypeVerify = "2fa_verify" // 已绑定，需提交验证码
+	challengeTypeEnroll = "2fa_enroll" // 策略强制但未绑定，需先完成绑定
+
+	challengeTTL      = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpSkew          = 1 // 允许前后各一个时间步的时钟偏差
+)
+
+// TwoFactorChallengeRequest 携带挑战令牌的请求
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+}
+
+// TwoFactorVerifyRequest 登录第二步：提交 TOTP 验证码或恢复码
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
+
+// TwoFactorEnrollRequest 策略强制绑定：提交挑战令牌与首个验证码
+type TwoFactorEnrollRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorCodeRequest 已登录用户提交验证码
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// signChallengeToken 签发短期挑战令牌
+func signChallengeToken(user *models.User, typ string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": user.ID,
+		"typ": typ,
+		"exp": now.Add(challengeTTL).Unix(),
+		"iat": now.Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(sessionSecret()))
+}
+
+// secondFactorChallengeType 登录还需完成的双因素认证：已启用时为校验，策略强制但未绑定时为绑定，否则为空
+func secondFactorChallengeType(user *models.User) string {
+	if user.TOTPEnabled {
+		return challengeTypeVerify
+	}
+	if models.GetBoolSetting(models.SettingRequire2FA, false) {
+		return challengeTypeEnroll
+	}
+	return ""
+}
+
+// respondTwoFactorChallenge 返回需要双因素认证的登录结果
+func respondTwoFactorChallenge(c *gin.Context, user *models.User, typ string) {
+	s, err := signChallengeToken(user, typ)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"twoFactorRequired": true,
+		"setupRequired":     typ == challengeTypeEnroll,
+		"challengeToken":    s,
+		"expiresIn":         int(challengeTTL.Seconds()),
+	}})
+}
+
+// loadChallengeUser 解析挑战令牌并加载对应用户
+func loadChallengeUser(tokenString, typ string) (*models.User, error) {
+	tok, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		return []byte(sessionSecret()), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil || !tok.Valid {
+		return nil, errors.New("invalid or expired challenge token")
+	}
+	claims, ok := tok.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid challenge token")
+	}
+	if t, _ := claims["typ"].(string); t != typ {
+		return nil, errors.New("invalid challenge token")
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, errors.New("invalid challenge token")
+	}
+	var user models.User
+	if err := models.DB.Where("id = ? AND is_active = ?", sub, true).First(&user).Error; err != nil {
+		return nil, errors.New("user inactive or not found")
+	}
+	// 连续校验失败被锁定时，此前签发的挑战令牌全部作废
+	if user.TOTPLockedAt != nil {
+		if iat, _ := claims["iat"].(float64); int64(iat) <= user.TOTPLockedAt.Unix() {
+			return nil, errors.New("challenge token has been invalidated, please log in again")
+		}
+	}
+	return &user, nil
+}
+
+// TwoFactorVerify 登录第二步：校验 TOTP 验证码或恢复码后签发会话 JWT
+func TwoFactorVerify(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, err := loadChallengeUser(req.ChallengeToken, challengeTypeVerify)
+	if err != nil {
+		metrics.AuthFailure(metrics.ReasonInvalidChallenge)
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Two-factor authentication is not enabled"})
+		return
+	}
+	if err := checkSecondFactor(user, req.Code, req.RecoveryCode); err != nil {
+		respondSecondFactorError(c, err)
+		return
+	}
+	respondSession(c, user)
+}
+
+// TwoFactorEnrollSetup 策略强制绑定第一步：凭挑战令牌生成密钥
+func TwoFactorEnrollSetup(c *gin.Context) {
+	var req TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, err := loadChallengeUser(req.ChallengeToken, challengeTypeEnroll)
+	if err != nil {
+		metrics.AuthFailure(metrics.ReasonInvalidChallenge)
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	// 挑战令牌签发后用户可能已完成绑定，不允许借残留的令牌替换或关闭已启用的 TOTP
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": "Two-factor authentication is already enabled"})
+		return
+	}
+	respondTOTPSetup(c, user)
+}
+
+// TwoFactorEnrollConfirm 策略强制绑定第二步：校验首个验证码，启用后直接登录
+func TwoFactorEnrollConfirm(c *gin.Context) {
+	var req TwoFactorEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, err := loadChallengeUser(req.ChallengeToken, challengeTypeEnroll)
+	if err != nil {
+		metrics.AuthFailure(metrics.ReasonInvalidChallenge)
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	codes, err := enableTOTP(user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	s, err := issueSessionToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"token":         s,
+		"user":          gin.H{"id": user.ID, "username": user.Username, "email": user.Email},
+		"recoveryCodes": codes,
+	}})
+}
+
+// SetupTOTP 已登录用户生成（或重新生成）待启用的 TOTP 密钥
+func SetupTOTP(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Two-factor authentication is already enabled"})
+		return
+	}
+	respondTOTPSetup(c, user)
+}
+
+// EnableTOTP 已登录用户提交验证码以启用 TOTP，返回一次性恢复码
+func EnableTOTP(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := enableTOTP(user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	// 启用后此前的会话全部失效，为当前会话签发新令牌
+	s, err := issueSessionToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"token": s, "recoveryCodes": codes}})
+}
+
+// DisableTOTP 已登录用户凭验证码或恢复码关闭 TOTP（管理员强制策略下不可关闭）
+func DisableTOTP(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Two-factor authentication is not enabled"})
+		return
+	}
+	if models.GetBoolSetting(models.SettingRequire2FA, false) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Two-factor authentication is required by administrator"})
+		return
+	}
+	if err := checkSecondFactor(user, req.Code, req.RecoveryCode); err != nil {
+		respondSecondFactorError(c, err)
+		return
+	}
+	if err := resetTOTP(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to disable two-factor authentication: " + err.Error()})
+		return
+	}
+	// 关闭后此前的会话全部失效，为当前会话签发新令牌
+	user, ok = loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	s, err := issueSessionToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"token": s}})
+}
+
+// RegenerateRecoveryCodes 凭当前验证码重新生成恢复码（旧恢复码全部作废）
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Two-factor authentication is not enabled"})
+		return
+	}
+	if err := checkSecondFactor(user, req.Code, ""); err != nil {
+		respondSecondFactorError(c, err)
+		return
+	}
+	var codes []string
+	err := reqDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to generate recovery codes: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"recoveryCodes": codes}})
+}
+
+// loadCurrentUser 加载当前认证用户，失败时已写出响应
+func loadCurrentUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := reqDB(c).Where("id = ?", c.GetString("userID")).First(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to load user: " + err.Error()})
+		return nil, false
+	}
+	return &user, true
+}
+
+// respondTOTPSetup 生成新密钥写入用户（未启用状态），返回密钥与 otpauth URI
+func respondTOTPSetup(c *gin.Context, user *models.User) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to generate secret"})
+		return
+	}
+	if err := reqDB(c).Model(user).Updates(map[string]interface{}{
+		"totp_secret":       secret,
+		"totp_enabled":      false,
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to save secret: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"secret":     secret,
+		"otpauthUri": totp.KeyURI(totpIssuer(), user.Username, secret),
+	}})
+}
+
+// enableTOTP 校验首个验证码，启用 TOTP 并生成恢复码；会话版本递增，此前签发的会话全部失效
+func enableTOTP(user *models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor setup has not been started")
+	}
+	counter, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, errors.New("invalid verification code")
+	}
+	var codes []string
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"totp_enabled":      true,
+			"totp_last_counter": counter,
+			"session_version":   gorm.Expr("session_version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("session_version").Where("id = ?", user.ID).First(user).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled, user.TOTPLastCounter = true, counter
+	return codes, nil
+}
+
+// resetTOTP 清除用户的 TOTP 设置与恢复码；会话版本递增，此前签发的会话全部失效
+func resetTOTP(userID string) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":       "",
+			"totp_enabled":      false,
+			"totp_last_counter": 0,
+			"totp_failures":     0,
+			"totp_locked_at":    nil,
+			"session_version":   gorm.Expr("session_version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// 计入连续失败次数的校验错误
+var (
+	errInvalidCode         = errors.New("invalid verification code")
+	errCodeUsed            = errors.New("verification code already used")
+	errInvalidRecoveryCode = errors.New("invalid recovery code")
+)
+
+// secondFactorLockedError 连续校验失败达到 twoFactor.maxAttempts 后的锁定
+type secondFactorLockedError struct {
+	until time.Time
+}
+
+func (e *secondFactorLockedError) Error() string {
+	return "too many failed verification attempts, try again later"
+}
+
+// respondSecondFactorError 返回二次验证失败：锁定时为 429 与 Retry-After，其余为 401
+func respondSecondFactorError(c *gin.Context, err error) {
+	var locked *secondFactorLockedError
+	if errors.As(err, &locked) {
+		metrics.AuthFailure(metrics.ReasonSecondFactorLocked)
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(locked.until).Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	metrics.AuthFailure(metrics.ReasonInvalidSecondFactor)
+	c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": err.Error()})
+}
+
+// checkSecondFactor 校验 TOTP 验证码或恢复码：锁定期间直接拒绝，错误计数，成功后清零
+func checkSecondFactor(user *models.User, code, recoveryCode string) error {
+	lockout := config.Duration(config.Get().TwoFactor.Lockout)
+	if user.TOTPLockedAt != nil {
+		if until := user.TOTPLockedAt.Add(lockout); time.Now().Before(until) {
+			return &secondFactorLockedError{until: until}
+		}
+	}
+	err := verifySecondFactor(user, code, recoveryCode)
+	switch {
+	case err == nil:
+		if user.TOTPFailures > 0 {
+			return models.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_failures", 0).Error
+		}
+		return nil
+	case errors.Is(err, errInvalidCode), errors.Is(err, errCodeUsed), errors.Is(err, errInvalidRecoveryCode):
+		if lerr := recordSecondFactorFailure(user.ID, lockout); lerr != nil {
+			return lerr
+		}
+	}
+	return err
+}
+
+// recordSecondFactorFailure 失败次数加一，达到上限时锁定并清零计数，返回锁定错误
+func recordSecondFactorFailure(userID string, lockout time.Duration) error {
+	if err := models.DB.Model(&models.User{}).Where("id = ?", userID).
+		Update("totp_failures", gorm.Expr("totp_failures + 1")).Error; err != nil {
+		return err
+	}
+	var user models.User
+	if err := models.DB.Select("totp_failures").Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+	maxAttempts := config.Get().TwoFactor.MaxAttempts
+	if user.TOTPFailures < maxAttempts {
+		return nil
+	}
+	now := time.Now()
+	// 条件更新：并发的失败请求只有一个执行锁定
+	if err := models.DB.Model(&models.User{}).Where("id = ? AND totp_failures >= ?", userID, maxAttempts).
+		Updates(map[string]interface{}{"totp_failures": 0, "totp_locked_at": &now}).Error; err != nil {
+		return err
+	}
+	return &secondFactorLockedError{until: now.Add(lockout)}
+}
+
+// verifySecondFactor 校验 TOTP 验证码（拒绝重放）或消耗一个恢复码
+func verifySecondFactor(user *models.User, code, recoveryCode string) error {
+	if code = strings.TrimSpace(code); code != "" {
+		counter, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return errInvalidCode
+		}
+		// 条件更新保证同一时间步的验证码只能使用一次
+		res := models.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+			Update("totp_last_counter", counter)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errCodeUsed
+		}
+		return nil
+	}
+
+	if recoveryCode = normalizeRecoveryCode(recoveryCode); recoveryCode != "" {
+		now := time.Now()
+		res := models.DB.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(recoveryCode)).
+			Update("used_at", &now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvalidRecoveryCode
+		}
+		return nil
+	}
+
+	return errors.New("verification code or recovery code required")
+}
+
+// replaceRecoveryCodes 作废旧恢复码并生成新的一组，返回明文（仅此一次）
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := randomToken(5)
+		code := raw[:5] + "-" + raw[5:]
+		rc := &models.RecoveryCode{
+			ID:       "rc_" + randomToken(8),
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		}
+		if err := tx.Create(rc).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格与连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// totpIssuer 认证器 App 中显示的签发方名称
+func totpIssuer() string {
+	return config.Get().TwoFactor.Issuer
+}
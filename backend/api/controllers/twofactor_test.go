@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/totp"
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// setupTestDB 在临时目录中初始化 SQLite 数据库与测试配置
+func setupTestDB(t *testing.T) *config.Config {
+	t.Helper()
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	cfg.Database.LogMode = "silent"
+	cfg.Session.Secret = "test-session-secret-0123456789"
+	cfg.TwoFactor.MaxAttempts = 3
+	config.Set(cfg)
+	if err := models.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { models.Close() })
+	return cfg
+}
+
+// createTOTPUser 创建已启用 TOTP 的用户
+func createTOTPUser(t *testing.T, id string) *models.User {
+	t.Helper()
+	user := &models.User{ID: id, Username: id, Email: id + "@example.com", IsActive: true,
+		TOTPSecret: testTOTPSecret, TOTPEnabled: true}
+	if err := models.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// reloadUser 读取数据库中的最新状态（失败计数、锁定时间等）
+func reloadUser(t *testing.T, id string) *models.User {
+	t.Helper()
+	var user models.User
+	if err := models.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func codeAt(t *testing.T, counter int64) string {
+	t.Helper()
+	code, err := totp.GenerateCode(testTOTPSecret, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// freshStep 临近时间步边界时等到下一个时间步开始，避免测试期间跨越边界，返回当前计数器
+func freshStep() int64 {
+	if left := totp.Period - time.Now().Unix()%totp.Period; left <= 2 {
+		time.Sleep(time.Duration(left) * time.Second)
+	}
+	return totp.Counter(time.Now())
+}
+
+// wrongCode 与当前时间窗口内任何验证码都不同的 6 位数字
+func wrongCode(t *testing.T) string {
+	t.Helper()
+	now := totp.Counter(time.Now())
+	for _, c := range []string{"000000", "111111", "222222", "333333"} {
+		if c != codeAt(t, now-1) && c != codeAt(t, now) && c != codeAt(t, now+1) {
+			return c
+		}
+	}
+	t.Fatal("no wrong code available")
+	return ""
+}
+
+func TestVerifySecondFactorTOTP(t *testing.T) {
+	setupTestDB(t)
+	user := createTOTPUser(t, "u-totp")
+	now := freshStep()
+
+	steps := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"wrong code", wrongCode(t), errInvalidCode},
+		{"previous step", codeAt(t, now-1), nil},
+		{"current step", codeAt(t, now), nil},
+		{"replayed current step", codeAt(t, now), errCodeUsed},
+		{"older step after newer one", codeAt(t, now-1), errCodeUsed},
+		{"step outside the window", codeAt(t, now+3), errInvalidCode},
+	}
+	for _, s := range steps {
+		if err := verifySecondFactor(user, s.code, ""); !errors.Is(err, s.want) {
+			t.Fatalf("%s: verifySecondFactor() = %v, want %v", s.name, err, s.want)
+		}
+	}
+	if got := reloadUser(t, user.ID).TOTPLastCounter; got != now {
+		t.Errorf("TOTPLastCounter = %d, want %d", got, now)
+	}
+}
+
+func TestVerifySecondFactorRecoveryCode(t *testing.T) {
+	setupTestDB(t)
+	user := createTOTPUser(t, "u-recovery")
+	other := createTOTPUser(t, "u-other")
+	codes, err := replaceRecoveryCodes(models.DB, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCodes, err := replaceRecoveryCodes(models.DB, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	steps := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"first use", codes[0], nil},
+		{"second use", codes[0], errInvalidRecoveryCode},
+		{"uppercase without hyphen", strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")), nil},
+		{"another user's code", otherCodes[0], errInvalidRecoveryCode},
+		{"unknown code", "aaaaa-bbbbb", errInvalidRecoveryCode},
+	}
+	for _, s := range steps {
+		if err := verifySecondFactor(user, "", s.code); !errors.Is(err, s.want) {
+			t.Fatalf("%s: verifySecondFactor() = %v, want %v", s.name, err, s.want)
+		}
+	}
+
+	// 重新生成后旧恢复码全部作废
+	if _, err := replaceRecoveryCodes(models.DB, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySecondFactor(user, "", codes[2]); !errors.Is(err, errInvalidRecoveryCode) {
+		t.Errorf("replaced code: verifySecondFactor() = %v, want %v", err, errInvalidRecoveryCode)
+	}
+	if err := verifySecondFactor(user, "", ""); err == nil {
+		t.Error("empty code and recovery code accepted")
+	}
+}
+
+func TestCheckSecondFactorLockout(t *testing.T) {
+	cfg := setupTestDB(t)
+	user := createTOTPUser(t, "u-lockout")
+	now := freshStep()
+	bad := wrongCode(t)
+
+	// 失败次数未达上限时返回原错误，成功后计数清零
+	for i := 0; i < cfg.TwoFactor.MaxAttempts-1; i++ {
+		if err := checkSecondFactor(reloadUser(t, user.ID), bad, ""); !errors.Is(err, errInvalidCode) {
+			t.Fatalf("attempt %d: checkSecondFactor() = %v, want %v", i+1, err, errInvalidCode)
+		}
+	}
+	if got := reloadUser(t, user.ID).TOTPFailures; got != cfg.TwoFactor.MaxAttempts-1 {
+		t.Fatalf("TOTPFailures = %d, want %d", got, cfg.TwoFactor.MaxAttempts-1)
+	}
+	if err := checkSecondFactor(reloadUser(t, user.ID), codeAt(t, now), ""); err != nil {
+		t.Fatalf("valid code: checkSecondFactor() = %v", err)
+	}
+	if got := reloadUser(t, user.ID).TOTPFailures; got != 0 {
+		t.Fatalf("TOTPFailures after success = %d, want 0", got)
+	}
+
+	// 连续失败达到上限后锁定，锁定期间正确的恢复码也被拒绝
+	codes, err := replaceRecoveryCodes(models.DB, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locked *secondFactorLockedError
+	for i := 0; i < cfg.TwoFactor.MaxAttempts; i++ {
+		err = checkSecondFactor(reloadUser(t, user.ID), "", "zzzzz-zzzzz")
+	}
+	if !errors.As(err, &locked) {
+		t.Fatalf("attempt %d: checkSecondFactor() = %v, want lockout", cfg.TwoFactor.MaxAttempts, err)
+	}
+	if d := time.Until(locked.until); d <= 14*time.Minute || d > 15*time.Minute {
+		t.Errorf("locked until %v from now, want about 15m", d)
+	}
+	if err := checkSecondFactor(reloadUser(t, user.ID), "", codes[0]); !errors.As(err, &locked) {
+		t.Fatalf("while locked: checkSecondFactor() = %v, want lockout", err)
+	}
+
+	// 锁定到期后恢复校验，锁定期间被拒绝的恢复码未被消耗
+	expired := time.Now().Add(-16 * time.Minute)
+	if err := models.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_locked_at", &expired).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSecondFactor(reloadUser(t, user.ID), "", codes[0]); err != nil {
+		t.Fatalf("after lockout: checkSecondFactor() = %v", err)
+	}
+}
+
+func TestLoadChallengeUser(t *testing.T) {
+	cfg := setupTestDB(t)
+	user := createTOTPUser(t, "u-challenge")
+	inactive := createTOTPUser(t, "u-inactive")
+	if err := models.DB.Model(inactive).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	locked := createTOTPUser(t, "u-locked")
+	lockedAt := time.Now().Add(-time.Minute)
+	if err := models.DB.Model(locked).Update("totp_locked_at", &lockedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(method jwt.SigningMethod, claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(method, claims).SignedString([]byte(cfg.Session.Secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	claims := func(sub, typ string, iat time.Time) jwt.MapClaims {
+		c := jwt.MapClaims{"sub": sub, "iat": iat.Unix(), "exp": iat.Add(challengeTTL).Unix()}
+		if typ != "" {
+			c["typ"] = typ
+		}
+		return c
+	}
+	verifyToken, err := signChallengeToken(user, challengeTypeVerify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollToken, err := signChallengeToken(user, challengeTypeEnroll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		token string
+		typ   string
+		want  string // 加载到的用户 ID，为空表示拒绝
+	}{
+		{"verify token", verifyToken, challengeTypeVerify, user.ID},
+		{"enroll token", enrollToken, challengeTypeEnroll, user.ID},
+		{"enroll token used for verify", enrollToken, challengeTypeVerify, ""},
+		{"verify token used for enroll", verifyToken, challengeTypeEnroll, ""},
+		{"session token without typ", sign(jwt.SigningMethodHS256, claims(user.ID, "", now)), challengeTypeVerify, ""},
+		{"expired", sign(jwt.SigningMethodHS256, claims(user.ID, challengeTypeVerify, now.Add(-time.Hour))), challengeTypeVerify, ""},
+		{"missing exp", sign(jwt.SigningMethodHS256, jwt.MapClaims{"sub": user.ID, "typ": challengeTypeVerify}), challengeTypeVerify, ""},
+		{"other hmac alg", sign(jwt.SigningMethodHS512, claims(user.ID, challengeTypeVerify, now)), challengeTypeVerify, ""},
+		{"wrong secret", func() string {
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(user.ID, challengeTypeVerify, now)).SignedString([]byte("other"))
+			return s
+		}(), challengeTypeVerify, ""},
+		{"inactive user", sign(jwt.SigningMethodHS256, claims(inactive.ID, challengeTypeVerify, now)), challengeTypeVerify, ""},
+		{"unknown user", sign(jwt.SigningMethodHS256, claims("nobody", challengeTypeVerify, now)), challengeTypeVerify, ""},
+		{"issued before lockout", sign(jwt.SigningMethodHS256, claims(locked.ID, challengeTypeVerify, lockedAt.Add(-time.Second))), challengeTypeVerify, ""},
+		{"issued after lockout", sign(jwt.SigningMethodHS256, claims(locked.ID, challengeTypeVerify, now)), challengeTypeVerify, locked.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadChallengeUser(tt.token, tt.typ)
+			switch {
+			case tt.want == "" && err == nil:
+				t.Errorf("loadChallengeUser() accepted the token for user %s", got.ID)
+			case tt.want != "" && err != nil:
+				t.Errorf("loadChallengeUser() = %v", err)
+			case tt.want != "" && got.ID != tt.want:
+				t.Errorf("loadChallengeUser() user = %s, want %s", got.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecondFactorChallengeType(t *testing.T) {
+	setupTestDB(t)
+	enabled := createTOTPUser(t, "u-enabled")
+	plain := &models.User{ID: "u-plain"}
+
+	if got := secondFactorChallengeType(enabled); got != challengeTypeVerify {
+		t.Errorf("enabled user: got %q, want %q", got, challengeTypeVerify)
+	}
+	if got := secondFactorChallengeType(plain); got != "" {
+		t.Errorf("policy off: got %q, want none", got)
+	}
+	if err := models.SetSetting(models.SettingRequire2FA, "true"); err != nil {
+		t.Fatal(err)
+	}
+	if got := secondFactorChallengeType(plain); got != challengeTypeEnroll {
+		t.Errorf("policy on: got %q, want %q", got, challengeTypeEnroll)
+	}
+	if got := secondFactorChallengeType(enabled); got != challengeTypeVerify {
+		t.Errorf("policy on, enabled user: got %q, want %q", got, challengeTypeVerify)
+	}
+}
+
+func TestSessionVersionBump(t *testing.T) {
+	setupTestDB(t)
+	user := &models.User{ID: "u-session", Username: "u-session", Email: "u-session@example.com", IsActive: true,
+		TOTPSecret: testTOTPSecret}
+	if err := models.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 启用 2FA 后会话版本递增，新签发的令牌携带新版本
+	if _, err := enableTOTP(user, codeAt(t, freshStep())); err != nil {
+		t.Fatal(err)
+	}
+	if user.SessionVersion != 1 || reloadUser(t, user.ID).SessionVersion != 1 {
+		t.Fatalf("SessionVersion after enable = %d (db %d), want 1", user.SessionVersion, reloadUser(t, user.ID).SessionVersion)
+	}
+	s, err := issueSessionToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, _, err := jwt.NewParser().ParseUnverified(s, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sv, _ := tok.Claims.(jwt.MapClaims)["sv"].(float64); sv != 1 {
+		t.Errorf("session token sv = %v, want 1", sv)
+	}
+
+	// 重置 2FA 同样使已签发的会话失效
+	if err := resetTOTP(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloadUser(t, user.ID).SessionVersion; got != 2 {
+		t.Errorf("SessionVersion after reset = %d, want 2", got)
+	}
+}
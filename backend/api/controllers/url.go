@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/proxy"
+	"github.com/gin-gonic/gin"
+)
+
+// BaseURL 对外访问的根地址（含路径前缀，无末尾斜杠）。
+// 配置了 server.publicUrl 时直接使用；否则按请求推断，X-Forwarded-Proto / X-Forwarded-Host 仅在来自受信任代理时生效
+func BaseURL(c *gin.Context) string {
+	srv := config.Get().Server
+	if srv.PublicURL != "" {
+		return srv.PublicURL
+	}
+	return proxy.Scheme(c.Request) + "://" + strings.TrimSuffix(proxy.Host(c.Request), "/") + srv.BasePath
+}
+
+// ShareURL 分享查看页地址
+func ShareURL(c *gin.Context, shareID string) string {
+	return shareURLFrom(BaseURL(c), shareID)
+}
+
+// shareURLFrom 由已计算的根地址（BaseURL）拼出分享查看页地址，供批量生成链接时复用
+func shareURLFrom(baseURL, shareID string) string {
+	return baseURL + "/s/" + shareID
+}
+
+// publicPath 为站内路径加上浏览器可见的路径前缀，用于重定向与 Cookie 路径
+func publicPath(p string) string {
+	return config.Get().Server.PublicPath() + p
+}
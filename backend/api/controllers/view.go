@@ -1,164 +1,190 @@
 package controllers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
 	"github.com/ZeroHawkeye/siyuan-share-api/models"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-// GetShare 获取分享内容
+// GetShare 获取分享或引用块片段内容。渲染结果经 LRU 缓存，支持 ETag / Last-Modified 条件请求；浏览次数批量写入
 func GetShare(c *gin.Context) {
 	shareID := c.Param("id")
 
-	var share models.Share
-	if err := models.DB.Where("id = ?", shareID).First(&share).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+	share, err := loadRenderedShare(c, shareID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code": 1,
+				"msg":  "Share not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 1,
-			"msg":  "Share not found",
+			"msg":  "Failed to query share: " + err.Error(),
 		})
 		return
 	}
 
-	// 检查是否过期
-	if share.IsExpired() {
+	requirePassword, ok := authorizeShareView(c, share.grants)
+	if !ok {
+		return
+	}
+
+	// 增加浏览次数（含 304 响应）
+	views.add(shareID)
+	metrics.ShareViewed()
+
+	// 需要密码的分享不允许共享缓存保存；均要求客户端每次重新验证
+	if requirePassword {
+		c.Header("Cache-Control", "private, no-cache")
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+	c.Header("ETag", share.etag)
+	c.Header("Last-Modified", share.lastModified.Format(http.TimeFormat))
+	if notModified(c, share.etag, share.lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", share.body)
+}
+
+// authorizeShareView 按访问条件检查过期与密码，不满足时写入错误响应并返回 false。
+// 引用块片段在全部父分享过期后过期，可使用任一未过期父分享的密码
+func authorizeShareView(c *gin.Context, grants []shareGrant) (requirePassword bool, ok bool) {
+	live := liveGrants(grants, time.Now())
+	if len(live) == 0 {
 		c.JSON(http.StatusGone, gin.H{
 			"code": 1,
 			"msg":  "Share has expired",
 		})
-		return
+		return false, false
 	}
 
-	// 如果需要密码，验证密码
-	if share.RequirePassword {
+	requirePassword = requiresPassword(live)
+	if requirePassword {
 		password := c.Query("password")
 		if password == "" {
+			metrics.AuthFailure(metrics.ReasonSharePassword)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code": 1,
 				"msg":  "Password required",
 			})
-			return
+			return true, false
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(password)); err != nil {
+		if !checkPassword(live, password) {
+			metrics.AuthFailure(metrics.ReasonSharePassword)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code": 1,
 				"msg":  "Invalid password",
 			})
-			return
+			return true, false
 		}
 	}
+	return requirePassword, true
+}
+
+// blockRefPattern 匹配块引用: ((blockId)) 或 ((blockId "text")) 或 ((blockId 'text'))
+var blockRefPattern = regexp.MustCompile(`\(\(([0-9]{14,}-[0-9a-z]{7,})(?:\s+["']([^"']+)["'])?\)\)`)
 
-	// 增加浏览次数
-	models.DB.Model(&share).UpdateColumn("view_count", share.ViewCount+1)
+// refResolver 批量解析块引用的链接地址（块 ID → 链接），无法链接的块不在结果中
+type refResolver func(ctx context.Context, blockIDs []string) (map[string]string, error)
 
-	// 处理引用链接替换
-	content := share.Content
-	if share.References != "" {
-		var refs []models.BlockReference
-		if err := json.Unmarshal([]byte(share.References), &refs); err == nil {
-			// 获取 baseURL 用于构建引用块分享链接
-			baseURL := getBaseURL(c)
-			content = replaceBlockReferences(content, refs, baseURL, share.UserID)
+// shareRefResolver 分享中的引用：同一文档内的块链接到页内锚点，其余经 share_refs 解析为引用块片段链接
+func shareRefResolver(parent *models.Share, anchors map[string]string, baseURL string) refResolver {
+	return func(ctx context.Context, blockIDs []string) (map[string]string, error) {
+		snippets, err := models.ResolveBlockRefs(ctx, parent, blockIDs)
+		if err != nil {
+			return nil, err
 		}
+		return refLinks(snippets, anchors, baseURL), nil
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"code": 0,
-		"msg":  "success",
-		"data": gin.H{
-			"id":              share.ID,
-			"docTitle":        share.DocTitle,
-			"content":         content,
-			"requirePassword": share.RequirePassword,
-			"expireAt":        share.ExpireAt,
-			"viewCount":       share.ViewCount + 1,
-			"createdAt":       share.CreatedAt,
-		},
-	})
 }
 
-// getBaseURL 获取基础 URL
-func getBaseURL(c *gin.Context) string {
-	baseURL := c.GetHeader("X-Base-URL")
-	if baseURL == "" {
-		proto := c.GetHeader("X-Forwarded-Proto")
-		host := c.GetHeader("X-Forwarded-Host")
-		if proto == "" {
-			if c.Request.TLS != nil {
-				proto = "https"
-			} else {
-				proto = "http"
-			}
-		}
-		if host == "" {
-			host = c.Request.Host
+// snippetRefResolver 引用块片段中的嵌套引用在片段所有者范围内解析
+func snippetRefResolver(snippet *models.BlockSnippet, baseURL string) refResolver {
+	return func(ctx context.Context, blockIDs []string) (map[string]string, error) {
+		snippets, err := models.ResolveSnippetRefs(ctx, snippet, blockIDs)
+		if err != nil {
+			return nil, err
 		}
-		baseURL = proto + "://" + strings.TrimSuffix(host, "/")
+		return refLinks(snippets, nil, baseURL), nil
+	}
+}
+
+// refLinks 片段（块 ID → 片段 ID）与页内锚点（块 ID → 锚点）对应的链接，锚点优先
+func refLinks(snippets, anchors map[string]string, baseURL string) map[string]string {
+	links := make(map[string]string, len(snippets)+len(anchors))
+	for blockID, snippetID := range snippets {
+		links[blockID] = shareURLFrom(baseURL, snippetID)
+	}
+	for blockID, anchor := range anchors {
+		links[blockID] = "#" + anchor
 	}
-	return strings.TrimSuffix(baseURL, "/")
+	return links
 }
 
-// replaceBlockReferences 替换内容中的块引用为指向引用块分享的 URL
-func replaceBlockReferences(content string, refs []models.BlockReference, baseURL string, userID string) string {
+// replaceBlockReferences 替换内容中的块引用为链接：同一文档内的块链接到页内锚点，其他块链接到引用块片段。
+// 全部引用经 resolve 批量解析；未解析的引用按 render.unresolvedRefs 渲染
+func replaceBlockReferences(ctx context.Context, content string, refs []models.BlockReference, resolve refResolver) (string, error) {
 	// 构建块ID到内容的映射
 	blockMap := make(map[string]models.BlockReference)
 	for _, ref := range refs {
 		blockMap[ref.BlockID] = ref
 	}
 
-	// 匹配块引用: ((blockId)) 或 ((blockId "text")) 或 ((blockId 'text'))
-	blockRefPattern := regexp.MustCompile(`\(\(([0-9]{14,}-[0-9a-z]{7,})(?:\s+["']([^"']+)["'])?\)\)`)
-
-	result := blockRefPattern.ReplaceAllStringFunc(content, func(match string) string {
-		matches := blockRefPattern.FindStringSubmatch(match)
-		if len(matches) < 2 {
-			return match
+	// 单次扫描定位全部引用
+	locs := blockRefPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(locs) == 0 {
+		return content, nil
+	}
+	var blockIDs []string
+	seen := map[string]bool{}
+	for _, loc := range locs {
+		id := content[loc[2]:loc[3]]
+		if !seen[id] {
+			seen[id] = true
+			blockIDs = append(blockIDs, id)
 		}
+	}
+	resolved, err := resolve(ctx, blockIDs)
+	if err != nil {
+		return "", err
+	}
 
-		blockID := matches[1]
-		displayText := ""
-		if len(matches) > 2 {
-			displayText = matches[2]
-		}
+	render := config.Get().Render
+	var b strings.Builder
+	b.Grow(len(content))
+	last := 0
+	for _, loc := range locs {
+		b.WriteString(content[last:loc[0]])
+		last = loc[1]
 
-		// 查找引用块信息
-		ref, exists := blockMap[blockID]
-		if !exists {
-			// 引用块不存在,保留显示文本或使用默认文本
-			if displayText != "" {
-				return displayText
-			}
-			return "[引用]"
+		match := content[loc[0]:loc[1]]
+		blockID := content[loc[2]:loc[3]]
+		displayText := ""
+		if loc[4] >= 0 {
+			displayText = content[loc[4]:loc[5]]
 		}
 
-		// 查找该块的分享记录
-		var blockShare models.Share
-		err := models.DB.Where("user_id = ? AND doc_id = ?", userID, blockID).
-			Order("created_at DESC").
-			First(&blockShare).Error
-
-		if err != nil {
-			// 找不到块分享,降级显示为纯文本
-			if displayText != "" {
-				return displayText
-			}
-			if ref.Content != "" {
-				if len(ref.Content) > 30 {
-					return ref.Content[:30] + "..."
-				}
-				return ref.Content
-			}
-			return "[引用]"
+		ref := blockMap[blockID]
+		link, ok := resolved[blockID]
+		if !ok {
+			b.WriteString(renderUnresolvedRef(render, match, displayText, ref))
+			continue
 		}
 
-		// 生成指向块分享的 URL
-		blockShareURL := baseURL + "/s/" + blockShare.ID
-
 		// 确定显示文本
 		linkText := displayText
 		if linkText == "" {
@@ -166,17 +192,47 @@ func replaceBlockReferences(content string, refs []models.BlockReference, baseUR
 		}
 		if linkText == "" {
 			// 使用引用块内容的前30个字符作为显示文本
-			if len(ref.Content) > 30 {
-				linkText = ref.Content[:30] + "..."
-			} else if ref.Content != "" {
-				linkText = ref.Content
-			} else {
-				linkText = "引用"
-			}
+			linkText = truncateRunes(ref.Content, 30)
 		}
+		if linkText == "" {
+			linkText = "引用"
+		}
+
+		b.WriteString("[" + linkText + "](" + link + ")")
+	}
+	b.WriteString(content[last:])
+
+	return b.String(), nil
+}
 
-		return "[" + linkText + "](" + blockShareURL + ")"
-	})
+// renderUnresolvedRef 渲染找不到引用块片段的块引用
+func renderUnresolvedRef(render config.RenderConfig, match, displayText string, ref models.BlockReference) string {
+	switch render.UnresolvedRefs {
+	case config.UnresolvedRefRaw:
+		return match
+	case config.UnresolvedRefRemove:
+		return ""
+	case config.UnresolvedRefPlaceholder:
+		return render.UnresolvedPlaceholder
+	}
+	// 降级显示为纯文本
+	if displayText != "" {
+		return displayText
+	}
+	if ref.DisplayText != "" {
+		return ref.DisplayText
+	}
+	if text := truncateRunes(ref.Content, 30); text != "" {
+		return text
+	}
+	return render.UnresolvedPlaceholder
+}
 
-	return result
+// truncateRunes 按字符截断，超出时追加省略号
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
 }
@@ -0,0 +1,387 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/cache"
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/transform"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// renderedShare 缓存的公开分享或引用块片段：响应体在载入时序列化一次，ETag 为响应体哈希。
+// 密码与过期时间在每次读取时校验，不依赖缓存命中与否
+type renderedShare struct {
+	baseURL      string
+	grants       []shareGrant
+	lastModified time.Time
+	loadedAt     time.Time
+	body         []byte
+	etag         string
+}
+
+// shareGrant 访问条件。分享只有一项；引用块片段每个未删除的父分享一项，满足任一未过期的一项即可访问
+type shareGrant struct {
+	requirePassword bool
+	passwordHash    string
+	expireAt        time.Time
+}
+
+// liveGrants 未过期的访问条件
+func liveGrants(grants []shareGrant, now time.Time) []shareGrant {
+	live := make([]shareGrant, 0, len(grants))
+	for _, g := range grants {
+		if now.Before(g.expireAt) {
+			live = append(live, g)
+		}
+	}
+	return live
+}
+
+// requiresPassword 全部访问条件都要求密码时需要密码
+func requiresPassword(grants []shareGrant) bool {
+	for _, g := range grants {
+		if !g.requirePassword {
+			return false
+		}
+	}
+	return len(grants) > 0
+}
+
+// checkPassword 密码与任一要求密码的访问条件匹配
+func checkPassword(grants []shareGrant, password string) bool {
+	tried := map[string]bool{}
+	for _, g := range grants {
+		if !g.requirePassword || tried[g.passwordHash] {
+			continue
+		}
+		tried[g.passwordHash] = true
+		if bcrypt.CompareHashAndPassword([]byte(g.passwordHash), []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	shareCacheOnce sync.Once
+	shareCache     *cache.LRU[string, *renderedShare]
+	shareCacheTTL  time.Duration
+	// shareCacheGen 每次失效递增；载入期间发生失效时丢弃载入结果，避免写回旧数据
+	shareCacheGen atomic.Uint64
+)
+
+func renderCache() *cache.LRU[string, *renderedShare] {
+	shareCacheOnce.Do(func() {
+		cfg := config.Get().Cache
+		shareCache = cache.NewLRU[string, *renderedShare](cfg.ShareEntries)
+		shareCacheTTL = config.Duration(cfg.ShareTTL)
+	})
+	return shareCache
+}
+
+// InvalidateShares 分享更新或删除后使其缓存失效。
+// 引用其他分享的页面在引用目标变化时不主动失效，最长 cache.shareTTL 后刷新
+func InvalidateShares(ids ...string) {
+	shareCacheGen.Add(1)
+	renderCache().Remove(ids...)
+}
+
+// PurgeShareCache 清空分享缓存（批量删除、转移所有权等影响范围较大的操作）
+func PurgeShareCache() {
+	shareCacheGen.Add(1)
+	renderCache().Purge()
+}
+
+// loadRenderedShare 读取分享或引用块片段的渲染结果，未命中时查询数据库并渲染引用链接
+func loadRenderedShare(c *gin.Context, shareID string) (*renderedShare, error) {
+	baseURL := BaseURL(c)
+	lru := renderCache()
+	if rs, ok := lru.Get(shareID); ok && rs.baseURL == baseURL && time.Since(rs.loadedAt) < shareCacheTTL {
+		metrics.ShareCacheHit()
+		return rs, nil
+	}
+	metrics.ShareCacheMiss()
+
+	gen := shareCacheGen.Load()
+	rs, err := renderShare(c, shareID, baseURL)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		rs, err = renderSnippet(c, shareID, baseURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if shareCacheGen.Load() == gen {
+		lru.Add(shareID, rs)
+	}
+	return rs, nil
+}
+
+// renderShare 渲染分享
+func renderShare(c *gin.Context, shareID, baseURL string) (*renderedShare, error) {
+	var share models.Share
+	if err := reqDB(c).Where("id = ?", shareID).First(&share).Error; err != nil {
+		return nil, err
+	}
+
+	// 处理引用链接替换（同一文档内的块链接到页内锚点，其他块链接到引用块片段）并展开嵌入块
+	var refs []models.BlockReference
+	var anchors map[string]string
+	if share.References != "" {
+		_ = json.Unmarshal([]byte(share.References), &refs)
+	}
+	if share.Anchors != "" {
+		_ = json.Unmarshal([]byte(share.Anchors), &anchors)
+	}
+	r := &contentRenderer{resolve: shareRefResolver(&share, anchors, baseURL), scope: []string{share.ID}, baseURL: baseURL}
+	doc := &transform.Document{
+		Stage:   transform.StageView,
+		Kind:    transform.KindShare,
+		ID:      share.ID,
+		Title:   share.DocTitle,
+		Content: share.Content,
+		URL:     shareURLFrom(baseURL, share.ID),
+		Refs: func(ctx context.Context, content string) (string, error) {
+			return r.render(ctx, content, refs, map[string]bool{}, 0)
+		},
+		Resolve: r.resolve,
+	}
+	if err := runViewTransformers(c, share.UserID, share.Pipeline, doc); err != nil {
+		return nil, err
+	}
+
+	grants := []shareGrant{{
+		requirePassword: share.RequirePassword,
+		passwordHash:    share.PasswordHash,
+		expireAt:        share.ExpireAt,
+	}}
+	return newRenderedShare(baseURL, grants, share.UpdatedAt, gin.H{
+		"id":              share.ID,
+		"docTitle":        doc.Title,
+		"content":         doc.Content,
+		"anchors":         anchors, // 查看页据此将 #block-<块ID> 深链接定位到锚点
+		"requirePassword": share.RequirePassword,
+		"expireAt":        share.ExpireAt,
+		"viewCount":       share.ViewCount + views.pendingFor(share.ID),
+		"createdAt":       share.CreatedAt,
+	})
+}
+
+// renderSnippet 渲染引用块片段，访问条件取自全部未删除的父分享；没有父分享时视为不存在
+func renderSnippet(c *gin.Context, snippetID, baseURL string) (*renderedShare, error) {
+	var snippet models.BlockSnippet
+	if err := reqDB(c).Where("id = ?", snippetID).First(&snippet).Error; err != nil {
+		return nil, err
+	}
+	byID, err := models.SnippetParents(c.Request.Context(), snippet.ID)
+	if err != nil {
+		return nil, err
+	}
+	parents := byID[snippet.ID]
+	if len(parents) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	// 嵌套引用链接到同一所有者的其他片段；嵌入块限于全部父分享都引用的片段
+	var refs []models.BlockReference
+	if snippet.References != "" {
+		_ = json.Unmarshal([]byte(snippet.References), &refs)
+	}
+	scope := make([]string, len(parents))
+	for i, p := range parents {
+		scope[i] = p.ID
+	}
+	// 片段使用所有者的用户设置（团队片段使用服务端默认）
+	r := &contentRenderer{resolve: snippetRefResolver(&snippet, baseURL), scope: scope, baseURL: baseURL}
+	doc := &transform.Document{
+		Stage:   transform.StageView,
+		Kind:    transform.KindSnippet,
+		ID:      snippet.ID,
+		BlockID: snippet.BlockID,
+		Title:   snippet.Title,
+		Content: snippet.Content,
+		URL:     shareURLFrom(baseURL, snippet.ID),
+		Refs: func(ctx context.Context, content string) (string, error) {
+			return r.render(ctx, content, refs, map[string]bool{snippet.ID: true}, 0)
+		},
+		Resolve: r.resolve,
+	}
+	if err := runViewTransformers(c, snippet.UserID, "", doc); err != nil {
+		return nil, err
+	}
+
+	access := snippetAccessOf(parents, time.Now())
+	lastModified := snippet.UpdatedAt
+	for _, p := range parents {
+		if p.UpdatedAt.After(lastModified) {
+			lastModified = p.UpdatedAt
+		}
+	}
+	return newRenderedShare(baseURL, access.grants, lastModified, gin.H{
+		"id":              snippet.ID,
+		"docTitle":        doc.Title,
+		"content":         doc.Content,
+		"requirePassword": access.requirePassword,
+		"expireAt":        access.expireAt,
+		"viewCount":       snippet.ViewCount + views.pendingFor(snippet.ID),
+		"createdAt":       snippet.CreatedAt,
+	})
+}
+
+// snippetAccess 引用块片段由父分享决定的访问条件（级联规则）：
+//   - 父分享删除或不再引用该块：不再授予访问，没有父分享引用时片段被删除；
+//   - 父分享过期：不再授予访问，全部父分享过期后片段过期（expireAt 为父分享中最晚的过期时间）；
+//   - 父分享设置或修改密码：任一未过期的父分享无需密码时片段公开，否则需要任一未过期父分享的密码。
+//
+// 父分享变化时片段无需单独更新，查看时按当前父分享计算
+type snippetAccess struct {
+	grants          []shareGrant
+	status          string
+	requirePassword bool
+	expireAt        time.Time
+}
+
+// 分享与引用块片段的状态
+const (
+	shareStatusActive  = "active"
+	shareStatusExpired = "expired"
+)
+
+func snippetAccessOf(parents []models.Share, now time.Time) snippetAccess {
+	a := snippetAccess{grants: make([]shareGrant, 0, len(parents)), status: shareStatusExpired}
+	for _, p := range parents {
+		a.grants = append(a.grants, shareGrant{
+			requirePassword: p.RequirePassword,
+			passwordHash:    p.PasswordHash,
+			expireAt:        p.ExpireAt,
+		})
+		if p.ExpireAt.After(a.expireAt) {
+			a.expireAt = p.ExpireAt
+		}
+	}
+	// requirePassword 按未过期的父分享计算，全部过期时按全部父分享
+	live := liveGrants(a.grants, now)
+	if len(live) > 0 {
+		a.status = shareStatusActive
+	} else {
+		live = a.grants
+	}
+	a.requirePassword = requiresPassword(live)
+	return a
+}
+
+// newRenderedShare 序列化响应体并计算 ETag。
+// 浏览次数为载入时的值（含尚未写入数据库的部分），在条目刷新前保持不变，以保证 ETag 对应确定的响应体
+func newRenderedShare(baseURL string, grants []shareGrant, lastModified time.Time, data gin.H) (*renderedShare, error) {
+	body, err := json.Marshal(gin.H{
+		"code": 0,
+		"msg":  "success",
+		"data": data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	return &renderedShare{
+		baseURL:      baseURL,
+		grants:       grants,
+		lastModified: lastModified.UTC().Truncate(time.Second),
+		loadedAt:     time.Now(),
+		body:         body,
+		etag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}, nil
+}
+
+// notModified 按 If-None-Match（优先）或 If-Modified-Since 判断客户端缓存是否仍然有效
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastModified.After(t)
+		}
+	}
+	return false
+}
+
+// viewCounter 在内存中累计浏览次数，定期批量写入数据库
+type viewCounter struct {
+	mu      sync.Mutex
+	pending map[string]int
+}
+
+var views = &viewCounter{pending: map[string]int{}}
+
+func (v *viewCounter) add(id string) {
+	v.mu.Lock()
+	v.pending[id]++
+	v.mu.Unlock()
+}
+
+func (v *viewCounter) pendingFor(id string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.pending[id]
+}
+
+// flush 写入累计的浏览次数；失败时合并回待写入队列，下次重试
+func (v *viewCounter) flush(ctx context.Context) error {
+	v.mu.Lock()
+	batch := v.pending
+	v.pending = map[string]int{}
+	v.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := models.AddShareViews(ctx, batch); err != nil {
+		v.mu.Lock()
+		for id, n := range batch {
+			v.pending[id] += n
+		}
+		v.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// RunViewCountFlusher 每隔 interval 批量写入浏览次数，ctx 取消后执行最后一次写入再返回
+func RunViewCountFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := views.flush(ctx); err != nil {
+				slog.Error("Failed to flush share view counts", "error", err)
+			}
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := views.flush(flushCtx); err != nil {
+				slog.Error("Failed to flush share view counts on shutdown", "error", err)
+			}
+			return
+		}
+	}
+}
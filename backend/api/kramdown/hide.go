@@ -0,0 +1,250 @@
+// Package kramdown 处理思源笔记导出的 kramdown 源码：块属性（IAL）解析、按属性移除块，以及转换为 Markdown。
+package kramdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// ialLinePattern 独立行的块 IAL（可位于引用块、列表项内）：{: id="..." ...}
+	ialLinePattern = regexp.MustCompile(`^((?:[ \t]*>)*[ \t]*)\{:\s*([^}]*)\}\s*$`)
+	// itemIALPattern 列表项开头的 IAL：* {: id="..."}内容
+	itemIALPattern = regexp.MustCompile(`^((?:[ \t]*>)*[ \t]*)(?:[-*+]|\d+[.)])[ \t]+\{:\s*([^}]*)\}`)
+	// attrPattern IAL 中的属性 name="value"
+	attrPattern = regexp.MustCompile(`([A-Za-z][\w-]*)="((?:[^"\\]|\\.)*)"`)
+	// listItemPattern 列表项标记行
+	listItemPattern = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d+[.)])[ \t]`)
+	// headingPattern ATX 标题
+	headingPattern = regexp.MustCompile(`^(#{1,6})[ \t]`)
+)
+
+// ParseIAL 解析 IAL 内容（不含 {: 与 }）中的属性
+func ParseIAL(s string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range attrPattern.FindAllStringSubmatch(s, -1) {
+		attrs[m[1]] = strings.ReplaceAll(m[2], `\"`, `"`)
+	}
+	return attrs
+}
+
+// line 按行拆分后的 kramdown
+type line struct {
+	text   string
+	prefix string // 行首缩进与引用标记
+	body   string // 去掉 prefix 后的内容
+	ial    map[string]string
+	item   bool // 列表项开头的 IAL（块从该行开始）
+}
+
+func splitLines(content string) []line {
+	raw := strings.Split(content, "\n")
+	lines := make([]line, len(raw))
+	for i, t := range raw {
+		l := line{text: t}
+		if m := ialLinePattern.FindStringSubmatch(t); m != nil {
+			l.prefix, l.ial = m[1], ParseIAL(m[2])
+		} else if m := itemIALPattern.FindStringSubmatch(t); m != nil {
+			l.prefix, l.ial, l.item = m[1], ParseIAL(m[2]), true
+		} else {
+			l.prefix = linePrefix(t)
+		}
+		l.body = strings.TrimPrefix(t, l.prefix)
+		lines[i] = l
+	}
+	return lines
+}
+
+// linePrefix 行首的缩进与引用标记
+func linePrefix(t string) string {
+	i := 0
+	for i < len(t) && (t[i] == ' ' || t[i] == '\t' || t[i] == '>') {
+		i++
+	}
+	return t[:i]
+}
+
+// level 前缀的嵌套深度：引用标记数与缩进宽度
+func level(prefix string) (quotes, indent int) {
+	for _, c := range prefix {
+		switch c {
+		case '>':
+			quotes++
+			indent = 0
+		case '\t':
+			indent += 4
+		case ' ':
+			indent++
+		}
+	}
+	return quotes, indent
+}
+
+// deeper a 是否比 b 嵌套更深（同一引用层级下缩进更多，或引用层级更多）
+func deeper(a, b string) bool {
+	qa, ia := level(a)
+	qb, ib := level(b)
+	if qa != qb {
+		return qa > qb
+	}
+	return ia > ib
+}
+
+func blank(l line) bool { return strings.TrimSpace(strings.ReplaceAll(l.text, ">", "")) == "" }
+
+// Stripped StripBlocks 的结果
+type Stripped struct {
+	Content  string
+	BlockIDs []string // 被移除的块 ID（含子块）
+	Blocks   int      // 被移除的块数（含子块与没有 id 的块）
+}
+
+// StripBlocks 移除 IAL 中带有 name="value" 属性的块及其子块：
+// 列表项的子块、引用块与超级块中的块随容器一起移除，标题移除到下一个同级或更高级标题之前。
+// 文档块本身的 IAL 不参与匹配
+func StripBlocks(content, name, value string) Stripped {
+	if name == "" || !strings.Contains(content, name+`="`) {
+		return Stripped{Content: content}
+	}
+	lines := splitLines(content)
+	removed := make([]bool, len(lines))
+	for i, l := range lines {
+		if removed[i] || l.ial == nil || l.ial[name] != value || l.ial["type"] == "doc" {
+			continue
+		}
+		start, end := i, i
+		if l.item {
+			end = itemEnd(lines, i)
+		} else {
+			start = nextNonBlank(lines, blockStart(lines, i), i)
+			if m := headingPattern.FindStringSubmatch(lines[start].body); m != nil {
+				end = headingEnd(lines, i, lines[start].prefix, len(m[1]))
+			}
+		}
+		for j := start; j <= end; j++ {
+			removed[j] = true
+		}
+	}
+
+	var res Stripped
+	seen := map[string]bool{}
+	out := make([]string, 0, len(lines))
+	dropped := false
+	for i, l := range lines {
+		if removed[i] {
+			if l.ial != nil {
+				res.Blocks++
+			}
+			if id := l.ial["id"]; id != "" && !seen[id] {
+				seen[id] = true
+				res.BlockIDs = append(res.BlockIDs, id)
+			}
+			dropped = true
+			continue
+		}
+		// 移除块后不留下连续空行
+		if dropped && blank(l) && (len(out) == 0 || blank(line{text: out[len(out)-1]})) {
+			continue
+		}
+		dropped = false
+		out = append(out, l.text)
+	}
+	res.Content = strings.Join(out, "\n")
+	return res
+}
+
+// blockStart 以第 end 行 IAL 结尾的块的起始行：向上找到同级的上一个块的 IAL、所在容器的开始，
+// 或没有 IAL 的上一个段落（空行分隔）。超级块 {{{ ... }}} 整体跳过
+func blockStart(lines []line, end int) int {
+	prefix := lines[end].prefix
+	super := 0
+	for j := end - 1; j >= 0; j-- {
+		l := lines[j]
+		if blank(l) {
+			if super == 0 && separatesBlocks(lines, j, prefix) {
+				return j + 1
+			}
+			continue
+		}
+		if deeper(prefix, l.prefix) {
+			// 离开所在容器；列表项首行同时是其第一个段落，整项一起移除
+			if listItemPattern.MatchString(l.text) {
+				return j
+			}
+			return j + 1
+		}
+		switch body := strings.TrimSpace(l.body); {
+		case l.prefix == prefix && body == "}}}":
+			super++
+			continue
+		case l.prefix == prefix && strings.HasPrefix(body, "{{{"):
+			if super == 0 {
+				return j + 1 // 超级块内的第一个块
+			}
+			super--
+			continue
+		}
+		if super == 0 && l.ial != nil && !l.item && l.prefix == prefix {
+			return j + 1
+		}
+	}
+	return 0
+}
+
+// separatesBlocks 第 j 行空行是否为块的边界：上方最近的非空行与块同级、不是 IAL 也不是列表的一部分。
+// 思源导出的块都以 IAL 结尾，由 IAL 判断边界；没有 IAL 的手写段落以空行为界
+func separatesBlocks(lines []line, j int, prefix string) bool {
+	for k := j - 1; k >= 0; k-- {
+		l := lines[k]
+		if blank(l) {
+			continue
+		}
+		return l.prefix == prefix && l.ial == nil && !listItemPattern.MatchString(l.text) &&
+			strings.TrimSpace(l.body) != "}}}"
+	}
+	return false
+}
+
+func nextNonBlank(lines []line, from, limit int) int {
+	for from < limit && blank(lines[from]) {
+		from++
+	}
+	return from
+}
+
+// itemEnd 第 i 行列表项的最后一行：其后缩进更深的行（子块）与空行
+func itemEnd(lines []line, i int) int {
+	itemPrefix := lines[i].prefix
+	end := i
+	for j := i + 1; j < len(lines); j++ {
+		if blank(lines[j]) {
+			continue
+		}
+		if !deeper(lines[j].prefix, itemPrefix) {
+			break
+		}
+		end = j
+	}
+	return end
+}
+
+// headingEnd 标题块（IAL 在第 i 行）及其下属块的最后一行：到下一个同级或更高级标题、或离开所在容器之前
+func headingEnd(lines []line, i int, prefix string, depth int) int {
+	end := i
+	for j := i + 1; j < len(lines); j++ {
+		l := lines[j]
+		if blank(l) {
+			continue
+		}
+		if deeper(prefix, l.prefix) || (l.prefix == prefix && strings.TrimSpace(l.body) == "}}}") {
+			break
+		}
+		if l.prefix == prefix {
+			if m := headingPattern.FindStringSubmatch(l.body); m != nil && len(m[1]) <= depth {
+				break
+			}
+		}
+		end = j
+	}
+	return end
+}
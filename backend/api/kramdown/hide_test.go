@@ -0,0 +1,189 @@
+package kramdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []string
+		want    []string
+		ids     []string // 被移除的块 ID
+		blocks  int
+	}{
+		{
+			name: "paragraph",
+			content: []string{
+				"公开",
+				`{: id="20240101120000-p000001"}`,
+				"",
+				"隐藏",
+				`{: id="20240101120000-p000002" custom-share="hide"}`,
+				"",
+				"结尾",
+				`{: id="20240101120000-p000003"}`,
+			},
+			want: []string{
+				"公开",
+				`{: id="20240101120000-p000001"}`,
+				"",
+				"结尾",
+				`{: id="20240101120000-p000003"}`,
+			},
+			ids:    []string{"20240101120000-p000002"},
+			blocks: 1,
+		},
+		{
+			name: "list item with children",
+			content: []string{
+				`* {: id="20240101120000-l000001"}公开项`,
+				`  {: id="20240101120000-l000002"}`,
+				`* {: id="20240101120000-l000003" custom-share="hide"}隐藏项`,
+				`  {: id="20240101120000-l000004"}`,
+				"",
+				`  * {: id="20240101120000-l000005"}子项`,
+				`    {: id="20240101120000-l000006"}`,
+				`* {: id="20240101120000-l000007"}最后一项`,
+				`  {: id="20240101120000-l000008"}`,
+				`{: id="20240101120000-list001"}`,
+			},
+			want: []string{
+				`* {: id="20240101120000-l000001"}公开项`,
+				`  {: id="20240101120000-l000002"}`,
+				`* {: id="20240101120000-l000007"}最后一项`,
+				`  {: id="20240101120000-l000008"}`,
+				`{: id="20240101120000-list001"}`,
+			},
+			ids:    []string{"20240101120000-l000003", "20240101120000-l000004", "20240101120000-l000005", "20240101120000-l000006"},
+			blocks: 4,
+		},
+		{
+			name: "nested list item",
+			content: []string{
+				`* {: id="20240101120000-n000001"}父项`,
+				`  {: id="20240101120000-n000002"}`,
+				`  * {: id="20240101120000-n000003" custom-share="hide"}隐藏子项`,
+				`    {: id="20240101120000-n000004"}`,
+				`  * {: id="20240101120000-n000005"}公开子项`,
+				`    {: id="20240101120000-n000006"}`,
+			},
+			want: []string{
+				`* {: id="20240101120000-n000001"}父项`,
+				`  {: id="20240101120000-n000002"}`,
+				`  * {: id="20240101120000-n000005"}公开子项`,
+				`    {: id="20240101120000-n000006"}`,
+			},
+			ids:    []string{"20240101120000-n000003", "20240101120000-n000004"},
+			blocks: 2,
+		},
+		{
+			name: "heading removes its section",
+			content: []string{
+				"## 公开",
+				`{: id="20240101120000-h000001"}`,
+				"",
+				"## 隐藏",
+				`{: id="20240101120000-h000002" custom-share="hide"}`,
+				"",
+				"小节正文",
+				`{: id="20240101120000-h000003"}`,
+				"",
+				"### 子标题",
+				`{: id="20240101120000-h000004"}`,
+				"",
+				"## 下一节",
+				`{: id="20240101120000-h000005"}`,
+			},
+			want: []string{
+				"## 公开",
+				`{: id="20240101120000-h000001"}`,
+				"",
+				"## 下一节",
+				`{: id="20240101120000-h000005"}`,
+			},
+			ids:    []string{"20240101120000-h000002", "20240101120000-h000003", "20240101120000-h000004"},
+			blocks: 3,
+		},
+		{
+			name: "blockquote",
+			content: []string{
+				"> 引用正文",
+				`> {: id="20240101120000-q000001"}`,
+				`{: id="20240101120000-q000002" custom-share="hide"}`,
+				"",
+				"公开",
+				`{: id="20240101120000-q000003"}`,
+			},
+			want: []string{
+				"公开",
+				`{: id="20240101120000-q000003"}`,
+			},
+			ids:    []string{"20240101120000-q000001", "20240101120000-q000002"},
+			blocks: 2,
+		},
+		{
+			name: "block inside blockquote",
+			content: []string{
+				"> 第一段",
+				`> {: id="20240101120000-b000001"}`,
+				">",
+				"> 隐藏段",
+				`> {: id="20240101120000-b000002" custom-share="hide"}`,
+				">",
+				"> 第三段",
+				`> {: id="20240101120000-b000003"}`,
+				`{: id="20240101120000-b000004"}`,
+			},
+			want: []string{
+				"> 第一段",
+				`> {: id="20240101120000-b000001"}`,
+				">",
+				"> 第三段",
+				`> {: id="20240101120000-b000003"}`,
+				`{: id="20240101120000-b000004"}`,
+			},
+			ids:    []string{"20240101120000-b000002"},
+			blocks: 1,
+		},
+		{
+			name: "other value is kept",
+			content: []string{
+				"正文",
+				`{: id="20240101120000-o000001" custom-share="show"}`,
+			},
+			want: []string{
+				"正文",
+				`{: id="20240101120000-o000001" custom-share="show"}`,
+			},
+		},
+		{
+			name: "document ial is ignored",
+			content: []string{
+				"正文",
+				`{: id="20240101120000-d000001"}`,
+				`{: id="20240101120000-doc0001" type="doc" custom-share="hide"}`,
+			},
+			want: []string{
+				"正文",
+				`{: id="20240101120000-d000001"}`,
+				`{: id="20240101120000-doc0001" type="doc" custom-share="hide"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StripBlocks(strings.Join(tt.content, "\n"), "custom-share", "hide")
+			if want := strings.Join(tt.want, "\n"); got.Content != want {
+				t.Errorf("Content mismatch\nwant: %q\ngot:  %q", want, got.Content)
+			}
+			if strings.Join(got.BlockIDs, ",") != strings.Join(tt.ids, ",") {
+				t.Errorf("BlockIDs = %v, want %v", got.BlockIDs, tt.ids)
+			}
+			if got.Blocks != tt.blocks {
+				t.Errorf("Blocks = %d, want %d", got.Blocks, tt.blocks)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package kramdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// inlineIALPattern 任意位置的 IAL（独立行、列表项开头、行内元素之后），移除后独立行留下空行
+	inlineIALPattern = regexp.MustCompile(`\{:\s*[^}]*?\}`)
+	// blockRefPattern 块引用 ((id))、((id "静态锚文本")) 与 ((id '动态锚文本'))
+	blockRefPattern = regexp.MustCompile(`\(\(([0-9]{14,}-[0-9a-z]{7,})(?:\s+(?:"([^"]+)"|'([^']+)'))?\)\)`)
+	// queryPattern 嵌入查询 {{...}}
+	queryPattern = regexp.MustCompile(`\{\{.+?\}\}`)
+	// blockEmbedPattern 按块 ID 嵌入的查询 {{select * from blocks where id='...'}}
+	blockEmbedPattern = regexp.MustCompile(`(?i)^\{\{\s*select\s+\*\s+from\s+blocks\s+where\s+id\s*=\s*['"]([0-9]{14,}-[0-9a-z]{7,})['"]\s*;?\s*\}\}$`)
+	// markPattern 高亮 ==文本==
+	markPattern = regexp.MustCompile(`==([^=\s](?:[^=\n]*[^=\s])?)==`)
+	// tagPattern 标签 #标签#，前一个字符不能是字母、数字、& 或 #（排除 C#、HTML 实体等）
+	tagPattern = regexp.MustCompile(`(^|[^\p{L}\p{N}_&#])#([^#\s()\[\]<>](?:[^#\n()\[\]<>]*[^#\s()\[\]<>])?)#`)
+	// superblockPattern 超级块的开始 {{{row / {{{col 与结束 }}}
+	superblockPattern = regexp.MustCompile(`^[ \t>]*(?:\{\{\{(?:row|col)?|\}\}\})\s*$`)
+	// fencePattern 代码块围栏
+	fencePattern = regexp.MustCompile("^[ \t>]*(```+|~~~+)")
+	// codeSpanPattern 行内代码
+	codeSpanPattern = regexp.MustCompile("(`+)[^`]*?(`+)")
+)
+
+// Options Normalize 选项，零值与思源分享插件的转换结果一致
+type Options struct {
+	// PreserveBlockRefs 保留块引用 ((id "文本"))，由服务端渲染为链接；否则转换为 [文本]（无文本时为 [引用]）
+	PreserveBlockRefs bool
+	// PreserveEmbeds 保留按块 ID 嵌入的查询，由服务端展开；其他嵌入查询总是移除
+	PreserveEmbeds bool
+}
+
+// Ref 内容中的块引用
+type Ref struct {
+	BlockID     string `json:"blockId"`
+	DisplayText string `json:"displayText,omitempty"`
+}
+
+// Normalized Normalize 的结果
+type Normalized struct {
+	Markdown string
+	Refs     []Ref    // 块引用（按出现顺序去重，保留第一个锚文本）
+	Embeds   []string // 按块 ID 嵌入的块
+	Tags     []string // 标签
+}
+
+// Normalize 将思源导出的 kramdown 转换为 Markdown：移除 IAL 与 YAML front matter，
+// 转换块引用、高亮与标签，展开超级块（保留其中的块），移除嵌入查询，整理全角空格与多余空行。
+// 代码块与行内代码中的内容保持不变
+func Normalize(content string, opts Options) Normalized {
+	var res Normalized
+	if content == "" {
+		return res
+	}
+	refSeen, embedSeen, tagSeen := map[string]bool{}, map[string]bool{}, map[string]bool{}
+
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	start := skipFrontMatter(lines)
+	fence := ""
+	for _, l := range lines[start:] {
+		if fence != "" {
+			out = append(out, l)
+			if closesFence(l, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(l); m != nil {
+			fence = m[1]
+			out = append(out, inlineIALPattern.ReplaceAllString(l, ""))
+			continue
+		}
+		if superblockPattern.MatchString(l) {
+			out = append(out, "")
+			continue
+		}
+
+		orig := l
+		l = outsideCode(l, func(s string) string {
+			s = inlineIALPattern.ReplaceAllString(s, "")
+			s = queryPattern.ReplaceAllStringFunc(s, func(q string) string {
+				m := blockEmbedPattern.FindStringSubmatch(q)
+				if m == nil {
+					return ""
+				}
+				if !embedSeen[m[1]] {
+					embedSeen[m[1]] = true
+					res.Embeds = append(res.Embeds, m[1])
+				}
+				if opts.PreserveEmbeds {
+					return q
+				}
+				return ""
+			})
+			s = blockRefPattern.ReplaceAllStringFunc(s, func(r string) string {
+				m := blockRefPattern.FindStringSubmatch(r)
+				text := m[2] + m[3]
+				if !refSeen[m[1]] {
+					refSeen[m[1]] = true
+					res.Refs = append(res.Refs, Ref{BlockID: m[1], DisplayText: text})
+				}
+				switch {
+				case opts.PreserveBlockRefs:
+					return r
+				case text != "":
+					return "[" + text + "]"
+				}
+				return "[引用]"
+			})
+			s = markPattern.ReplaceAllString(s, "<mark>$1</mark>")
+			return tagPattern.ReplaceAllStringFunc(s, func(t string) string {
+				m := tagPattern.FindStringSubmatch(t)
+				if !tagSeen[m[2]] {
+					tagSeen[m[2]] = true
+					res.Tags = append(res.Tags, m[2])
+				}
+				return m[1] + "#" + m[2]
+			})
+		})
+		// 引用块中只有 IAL 的行：引用块内的块之间已有 > 空行分隔，整行移除
+		if l != orig && strings.Contains(l, ">") && strings.Trim(l, " \t>") == "" {
+			continue
+		}
+		out = append(out, cleanFullWidthSpaces(l))
+	}
+
+	// 合并连续空行（代码块内除外）
+	kept := out[:0]
+	blanks := 0
+	fence = ""
+	for _, l := range out {
+		if fence == "" && l == "" {
+			if blanks++; blanks > 1 {
+				continue
+			}
+		} else {
+			blanks = 0
+		}
+		if m := fencePattern.FindStringSubmatch(l); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if closesFence(l, fence) {
+				fence = ""
+			}
+		}
+		kept = append(kept, l)
+	}
+	res.Markdown = strings.TrimSpace(strings.Join(kept, "\n"))
+	return res
+}
+
+// closesFence 是否为 fence 开始的代码块的结束围栏：同一字符、不短于开始围栏且没有信息字符串
+func closesFence(l, fence string) bool {
+	m := fencePattern.FindStringSubmatch(l)
+	if m == nil || !strings.HasPrefix(m[1], fence) {
+		return false
+	}
+	return strings.TrimSpace(strings.TrimLeft(l, " \t>")[len(m[1]):]) == ""
+}
+
+// skipFrontMatter YAML front matter（首行为 ---）之后的第一行
+func skipFrontMatter(lines []string) int {
+	if strings.TrimSpace(lines[0]) != "---" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// outsideCode 对行内代码之外的部分应用 fn
+func outsideCode(line string, fn func(string) string) string {
+	locs := codeSpanPattern.FindAllStringSubmatchIndex(line, -1)
+	if len(locs) == 0 {
+		return fn(line)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		// 开始与结束的反引号数量相同才是行内代码
+		if loc[3]-loc[2] != loc[5]-loc[4] {
+			continue
+		}
+		b.WriteString(fn(line[last:loc[0]]))
+		b.WriteString(line[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(fn(line[last:]))
+	return b.String()
+}
+
+// cleanFullWidthSpaces 整理全角空格：仅含空白的行清空，行首的转为等量半角空格（保留缩进），行尾的移除，其余连续的合并为一个半角空格
+func cleanFullWidthSpaces(l string) string {
+	if strings.TrimLeft(l, " \t　") == "" {
+		return ""
+	}
+	if !strings.Contains(l, "　") {
+		return l
+	}
+	body := strings.TrimLeft(l, "　")
+	indent := strings.Repeat(" ", len([]rune(l))-len([]rune(body)))
+	body = strings.TrimRight(body, "　")
+	return indent + strings.Join(strings.FieldsFunc(body, func(r rune) bool { return r == '　' }), " ")
+}
@@ -0,0 +1,69 @@
+package kramdown
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update 按当前实现重写 golden 文件（只写已存在的 .server.md），提交前须逐一核对差异：
+//
+//	go test ./kramdown -update
+var update = flag.Bool("update", false, "rewrite golden files from the current output")
+
+// TestNormalizeGolden testdata 下每个 <name>.kramdown 为思源导出的输入：
+//
+//	<name>.md         默认选项的输出，与思源分享插件（src/utils/kramdown-parser.ts）的转换结果一致
+//	<name>.server.md  可选：创建分享时 format 为 kramdown 所用选项（保留块引用与块嵌入）的输出
+func TestNormalizeGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.kramdown"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no testdata/*.kramdown files")
+	}
+
+	variants := []struct {
+		suffix   string
+		opts     Options
+		optional bool
+	}{
+		{".md", Options{}, false},
+		{".server.md", Options{PreserveBlockRefs: true, PreserveEmbeds: true}, true},
+	}
+	for _, in := range inputs {
+		src, err := os.ReadFile(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := strings.TrimSuffix(in, ".kramdown")
+		for _, v := range variants {
+			golden := base + v.suffix
+			t.Run(filepath.Base(golden), func(t *testing.T) {
+				want, err := os.ReadFile(golden)
+				if os.IsNotExist(err) && v.optional {
+					t.Skip("no golden file")
+				}
+				got := Normalize(string(src), v.opts).Markdown
+				if got != "" {
+					got += "\n"
+				}
+				if *update {
+					if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != string(want) {
+					t.Errorf("Normalize(%s) mismatch\nwant: %q\ngot:  %q", filepath.Base(in), want, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package logging 基于 log/slog 的统一日志：JSON / 文本输出、日志级别、请求 ID 透传与敏感信息脱敏。
+// Setup 之后标准库 log 包的输出也会经由同一个 slog Handler。
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+)
+
+type ctxKey struct{}
+
+// Setup 按配置初始化全局 slog 日志
+func Setup(cfg config.LogConfig) {
+	slog.SetDefault(slog.New(NewHandler(os.Stderr, cfg)))
+}
+
+// NewHandler 创建带请求 ID 注入的 Handler
+func NewHandler(w io.Writer, cfg config.LogConfig) slog.Handler {
+	opts := &slog.HandlerOptions{Level: ParseLevel(cfg.Level)}
+	var h slog.Handler
+	if cfg.Format == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return requestIDHandler{h}
+}
+
+// ParseLevel 解析 debug / info / warn / error，无法识别时为 info
+func ParseLevel(s string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// WithRequestID 将请求 ID 写入 context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// RequestID 读取 context 中的请求 ID
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// requestIDHandler 为带请求 ID 的 context 日志自动附加 request_id 字段
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+const redacted = "REDACTED"
+
+// sensitiveParams 需要在日志中隐藏的查询参数（分享密码、各类令牌、OIDC 授权码）
+var sensitiveParams = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"access_token":  true,
+	"id_token":      true,
+	"refresh_token": true,
+	"code":          true,
+	"state":         true,
+}
+
+// RedactQuery 隐藏查询串中的敏感参数值
+func RedactQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return redacted
+	}
+	changed := false
+	for k := range values {
+		if sensitiveParams[strings.ToLower(k)] {
+			values[k] = []string{redacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return raw
+	}
+	return values.Encode()
+}
+
+// RedactAuthorization 只保留认证方案（如 Bearer），隐藏凭据
+func RedactAuthorization(v string) string {
+	if v == "" {
+		return ""
+	}
+	if i := strings.IndexByte(v, ' '); i > 0 {
+		return v[:i] + " " + redacted
+	}
+	return redacted
+}
@@ -1,12 +1,27 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/ZeroHawkeye/siyuan-share-api/backup"
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/controllers"
+	"github.com/ZeroHawkeye/siyuan-share-api/logging"
 	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/proxy"
 	"github.com/ZeroHawkeye/siyuan-share-api/routes"
+	"github.com/ZeroHawkeye/siyuan-share-api/server"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,29 +29,157 @@ import (
 var staticFiles embed.FS
 
 func main() {
+	// 子命令（如 config check）
+	if handled, code := runCommand(os.Args[1:]); handled {
+		os.Exit(code)
+	}
+
+	configPath := flag.String("config", "", "配置文件路径（YAML/TOML，默认读取 CONFIG_FILE 或当前目录下的 config.yaml/config.toml）")
+	flag.Parse()
+
+	// 加载并校验配置
+	cfg, err := config.Load(*configPath)
+	if err == nil {
+		err = checkTransformers(cfg)
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	config.Set(cfg)
+	logging.Setup(cfg.Log)
+	if cfg.File != "" {
+		slog.Info("Loaded config file", "path", cfg.File)
+	}
+
+	// 设置 Gin 模式
+	gin.SetMode(cfg.Server.Mode)
+
 	// 初始化数据库
 	if err := models.InitDB(); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("Failed to initialize database", err)
 	}
 
 	// 移除引导令牌流程：用户通过注册与个人中心管理 Token
 
-	// 设置 Gin 模式
-	if os.Getenv("GIN_MODE") == "" {
-		gin.SetMode(gin.ReleaseMode)
+	// 收到 SIGINT/SIGTERM 时取消 ctx：停止接收新连接、排空进行中的请求与后台任务
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 定时快照
+	var background sync.WaitGroup
+	if cfg.Backup.Schedule {
+		mgr, err := backup.New(cfg, models.DB)
+		if err != nil {
+			fatal("Failed to start backup scheduler", err)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			mgr.RunScheduler(ctx, config.Duration(cfg.Backup.Interval), cfg.Backup.KeepDaily, cfg.Backup.KeepWeekly)
+		}()
 	}
 
+	// 分享浏览次数批量写入；在请求排空后停止并写入剩余部分
+	flushCtx, stopFlush := context.WithCancel(context.Background())
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		controllers.RunViewCountFlusher(flushCtx, config.Duration(cfg.Cache.ViewFlushInterval))
+	}()
+
 	// 创建路由
 	r := routes.SetupRouter(&staticFiles)
 
+	srv := &http.Server{
+		Handler:           r,
+		ReadTimeout:       config.Duration(cfg.Server.ReadTimeout),
+		ReadHeaderTimeout: config.Duration(cfg.Server.ReadHeaderTimeout),
+		WriteTimeout:      config.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       config.Duration(cfg.Server.IdleTimeout),
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	if cfg.Server.Socket != "" {
+		srv.ConnContext = proxy.MarkUnix
+	}
+	if cfg.Server.TLSEnabled() {
+		certs, err := server.NewCertReloader(cfg.Server.TLSCert, cfg.Server.TLSKey)
+		if err != nil {
+			fatal("Failed to load TLS certificate", err)
+		}
+		srv.TLSConfig = server.TLSConfig(certs)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			certs.Watch(ctx)
+		}()
+	}
+
+	ln, err := server.Listen(cfg.Server)
+	if err != nil {
+		fatal("Failed to listen", err)
+	}
+
 	// 启动服务器
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8088"
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("Server starting", "addr", ln.Addr().String(), "tls", cfg.Server.TLSEnabled(), "mode", cfg.Server.Mode)
+		if srv.TLSConfig != nil {
+			serveErr <- srv.ServeTLS(ln, "", "")
+		} else {
+			serveErr <- srv.Serve(ln)
+		}
+	}()
+
+	// HTTP → HTTPS 跳转
+	var redirect *http.Server
+	if cfg.Server.HTTPRedirectPort != "" {
+		redirect = &http.Server{
+			Addr:              ":" + cfg.Server.HTTPRedirectPort,
+			Handler:           server.RedirectHandler(cfg.Server.Port),
+			ReadHeaderTimeout: config.Duration(cfg.Server.ReadHeaderTimeout),
+			IdleTimeout:       config.Duration(cfg.Server.IdleTimeout),
+			ErrorLog:          srv.ErrorLog,
+		}
+		go func() {
+			slog.Info("HTTP redirect starting", "port", cfg.Server.HTTPRedirectPort)
+			serveErr <- redirect.ListenAndServe()
+		}()
 	}
 
-	log.Printf("Server starting on port %s...", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("Failed to start server", err)
+		}
+	case <-ctx.Done():
 	}
+	stop()
+
+	// 优雅停机：就绪探针先返回 503，drainDelay 内继续接收请求直到负载均衡摘除实例，
+	// 再停止监听并等待进行中的请求完成（最长 shutdownTimeout）
+	slog.Info("Shutting down, draining in-flight requests", "drainDelay", cfg.Server.DrainDelay, "timeout", cfg.Server.ShutdownTimeout)
+	controllers.SetDraining()
+	time.Sleep(config.Duration(cfg.Server.DrainDelay))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown did not complete", "error", err)
+	}
+	if redirect != nil {
+		redirect.Close()
+	}
+	stopFlush()
+	background.Wait()
+
+	if err := models.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// fatal 记录错误并退出
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
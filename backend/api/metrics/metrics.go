@@ -0,0 +1,165 @@
+// Package metrics 汇总 Prometheus 指标：HTTP 请求、分享读写、认证失败、数据库查询耗时、分享状态与 Go 运行时。
+// 本包不依赖 models，分享统计通过 SetShareStatsFunc 注入，数据库耗时通过 GormPlugin 采集。
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "siyuan_share"
+
+// 认证失败原因（auth_failures_total 的 reason 标签）
+const (
+	ReasonMissingHeader       = "missing_header"
+	ReasonMalformedHeader     = "malformed_header"
+	ReasonInvalidToken        = "invalid_token"
+	ReasonInactiveUser        = "inactive_user"
+	ReasonInvalidCredentials  = "invalid_credentials"
+	ReasonInvalidChallenge    = "invalid_challenge"
+	ReasonInvalidSecondFactor = "invalid_second_factor"
+	ReasonSecondFactorLocked  = "second_factor_locked"
+	ReasonSharePassword       = "share_password"
+	ReasonSSO                 = "sso"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, gin route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and gin route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	shareWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "share_writes_total",
+		Help:      "Document shares created or updated (re-published).",
+	}, []string{"op"})
+
+	shareViews = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "share_views_total",
+		Help:      "Successful public share views.",
+	})
+
+	shareCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "share_cache_requests_total",
+		Help:      "Public share render cache lookups by result (hit, miss).",
+	}, []string{"result"})
+
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Authentication failures by reason.",
+	}, []string{"reason"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database statement latency by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, shareWrites, shareViews, shareCache, authFailures, dbDuration,
+		&shareCollector{},
+	)
+}
+
+// Handler /metrics 处理器
+func Handler() http.Handler {
+	// 响应由 gzip 中间件统一压缩，避免重复压缩
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{DisableCompression: true})
+}
+
+// ObserveHTTP 记录一次 HTTP 请求；route 为 gin 路由模板，避免路径参数导致标签爆炸
+func ObserveHTTP(method, route string, status int, d time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+// ShareCreated 新建分享
+func ShareCreated() { shareWrites.WithLabelValues("create").Inc() }
+
+// ShareUpdated 重新发布已有分享
+func ShareUpdated() { shareWrites.WithLabelValues("update").Inc() }
+
+// ShareViewed 分享被成功查看
+func ShareViewed() { shareViews.Inc() }
+
+// ShareCacheHit 分享渲染缓存命中
+func ShareCacheHit() { shareCache.WithLabelValues("hit").Inc() }
+
+// ShareCacheMiss 分享渲染缓存未命中
+func ShareCacheMiss() { shareCache.WithLabelValues("miss").Inc() }
+
+// AuthFailure 记录认证失败
+func AuthFailure(reason string) { authFailures.WithLabelValues(reason).Inc() }
+
+// ShareStats 分享状态统计
+type ShareStats struct {
+	Active         int64 // 未过期的分享
+	PendingCleanup int64 // 已过期但尚未删除的分享
+}
+
+var (
+	statsMu   sync.RWMutex
+	statsFunc func() (ShareStats, error)
+)
+
+// SetShareStatsFunc 设置抓取时读取分享统计的函数
+func SetShareStatsFunc(fn func() (ShareStats, error)) {
+	statsMu.Lock()
+	statsFunc = fn
+	statsMu.Unlock()
+}
+
+var (
+	activeSharesDesc  = prometheus.NewDesc(namespace+"_shares_active", "Shares that have not expired.", nil, nil)
+	expiredSharesDesc = prometheus.NewDesc(namespace+"_shares_expired_pending_cleanup", "Expired shares not yet deleted.", nil, nil)
+)
+
+// shareCollector 每次抓取时查询一次分享统计
+type shareCollector struct{}
+
+func (shareCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSharesDesc
+	ch <- expiredSharesDesc
+}
+
+func (shareCollector) Collect(ch chan<- prometheus.Metric) {
+	statsMu.RLock()
+	fn := statsFunc
+	statsMu.RUnlock()
+	if fn == nil {
+		return
+	}
+	stats, err := fn()
+	if err != nil {
+		slog.Error("metrics: share stats failed", "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(activeSharesDesc, prometheus.GaugeValue, float64(stats.Active))
+	ch <- prometheus.MustNewConstMetric(expiredSharesDesc, prometheus.GaugeValue, float64(stats.PendingCleanup))
+}
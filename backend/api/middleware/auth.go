@@ -1,18 +1,26 @@
 package middleware
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
 	"github.com/ZeroHawkeye/siyuan-share-api/models"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
 )
 
+// 认证方式，写入 gin.Context 的 authType
+const (
+	AuthTypeSession = "session"
+	AuthTypeToken   = "token"
+)
+
 // AuthMiddleware 认证中间件：支持两种方式
 // 1) 会话 JWT（用于 Web 登录态）
 // 2) 用户 API Token（user_tokens 表，长期令牌，供插件/CLI 使用）
@@ -20,6 +28,7 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			metrics.AuthFailure(metrics.ReasonMissingHeader)
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Authorization header required"})
 			c.Abort()
 			return
@@ -27,6 +36,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
+			metrics.AuthFailure(metrics.ReasonMalformedHeader)
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid authorization header format"})
 			c.Abort()
 			return
@@ -34,18 +44,21 @@ func AuthMiddleware() gin.HandlerFunc {
 		raw := strings.TrimSpace(parts[1])
 
 		// 优先尝试解析为 JWT 会话令牌
-		if userID, ok := parseJWT(raw); ok {
+		if userID, ok := parseJWT(c.Request.Context(), raw); ok {
 			c.Set("userID", userID)
+			c.Set("authType", AuthTypeSession)
 			c.Next()
 			return
 		}
 
 		// 回退为 API Token：查 user_tokens 表
+		db := models.DB.WithContext(c.Request.Context())
 		hash := sha256.Sum256([]byte(raw))
 		tokenHash := hex.EncodeToString(hash[:])
 
 		var ut models.UserToken
-		if err := models.DB.Where("token_hash = ? AND revoked = ?", tokenHash, false).First(&ut).Error; err != nil {
+		if err := db.Where("token_hash = ? AND revoked = ?", tokenHash, false).First(&ut).Error; err != nil {
+			metrics.AuthFailure(metrics.ReasonInvalidToken)
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid or revoked token"})
 			c.Abort()
 			return
@@ -53,7 +66,8 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// 校验用户是否可用
 		var user models.User
-		if err := models.DB.Where("id = ? AND is_active = ?", ut.UserID, true).First(&user).Error; err != nil {
+		if err := db.Where("id = ? AND is_active = ?", ut.UserID, true).First(&user).Error; err != nil {
+			metrics.AuthFailure(metrics.ReasonInactiveUser)
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "User inactive or not found"})
 			c.Abort()
 			return
@@ -61,39 +75,74 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// 更新最近使用时间（不阻断主流程）
 		now := time.Now()
-		models.DB.Model(&ut).Update("last_used_at", &now)
+		db.Model(&ut).Update("last_used_at", &now)
 
 		c.Set("userID", user.ID)
 		c.Set("username", user.Username)
+		c.Set("authType", AuthTypeToken)
+		c.Next()
+	}
+}
+
+// SessionOnlyMiddleware 仅允许 Web 会话 JWT 访问（拒绝 API Token），
+// 用于双因素认证管理等敏感操作。需放在 AuthMiddleware 之后。
+func SessionOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authType") != AuthTypeSession {
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Web session required"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
-func parseJWT(tokenString string) (string, bool) {
+// AdminMiddleware 要求当前用户为管理员。需放在 AuthMiddleware 之后。
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user models.User
+		if err := models.DB.WithContext(c.Request.Context()).Where("id = ? AND is_active = ?", c.GetString("userID"), true).First(&user).Error; err != nil || !user.IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Admin privileges required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// parseJWT 校验会话 JWT（HS256、未过期、typ 为 session），并确认用户仍可用且会话版本与签发时一致
+func parseJWT(ctx context.Context, tokenString string) (string, bool) {
 	if strings.Count(tokenString, ".") != 2 {
 		return "", false
 	}
-	secret := os.Getenv("SESSION_SECRET")
-	if secret == "" {
-		secret = "dev-secret"
-	}
+	secret := config.Get().Session.Secret
 	tok, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		// 默认使用 HMAC 方法
 		return []byte(secret), nil
-	}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
 	if err != nil || !tok.Valid {
 		return "", false
 	}
-	if claims, ok := tok.Claims.(jwt.MapClaims); ok {
-		// 过期校验
-		if exp, has := claims["exp"].(float64); has {
-			if time.Unix(int64(exp), 0).Before(time.Now()) {
-				return "", false
-			}
-		}
-		if sub, has := claims["sub"].(string); has && sub != "" {
-			return sub, true
-		}
+	claims, ok := tok.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", false
+	}
+	// 仅接受会话令牌；双因素认证挑战令牌等其它用途的 JWT 不可用于访问接口
+	if typ, _ := claims["typ"].(string); typ != "session" {
+		return "", false
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return "", false
+	}
+	// 未携带 sv 的旧令牌视为版本 0
+	version, _ := claims["sv"].(float64)
+	var user models.User
+	if err := models.DB.WithContext(ctx).Select("id", "session_version").
+		Where("id = ? AND is_active = ?", sub, true).First(&user).Error; err != nil {
+		return "", false
+	}
+	if int64(version) != int64(user.SessionVersion) {
+		return "", false
 	}
-	return "", false
+	return sub, true
 }
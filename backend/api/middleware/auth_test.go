@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-session-secret-0123456789"
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	cfg.Database.LogMode = "silent"
+	cfg.Session.Secret = testSecret
+	config.Set(cfg)
+	if err := models.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { models.Close() })
+}
+
+func TestParseJWT(t *testing.T) {
+	setupTestDB(t)
+	users := []*models.User{
+		{ID: "u-active", Username: "active", Email: "active@example.com", IsActive: true},
+		{ID: "u-inactive", Username: "inactive", Email: "inactive@example.com", IsActive: true},
+		{ID: "u-bumped", Username: "bumped", Email: "bumped@example.com", IsActive: true, SessionVersion: 2},
+	}
+	for _, u := range users {
+		if err := models.DB.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// IsActive 默认值为 true，零值需单独更新
+	if err := models.DB.Model(users[1]).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	session := func(sub string, mutate func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{"sub": sub, "typ": "session", "sv": 0, "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()}
+		if mutate != nil {
+			mutate(c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	hs256 := func(claims jwt.MapClaims) string { return sign(jwt.SigningMethodHS256, []byte(testSecret), claims) }
+
+	tests := []struct {
+		name  string
+		token string
+		want  string // 为空表示拒绝
+	}{
+		{"valid", hs256(session("u-active", nil)), "u-active"},
+		{"token without sv is version 0", hs256(session("u-active", func(c jwt.MapClaims) { delete(c, "sv") })), "u-active"},
+		{"current session version", hs256(session("u-bumped", func(c jwt.MapClaims) { c["sv"] = 2 })), "u-bumped"},
+		{"stale session version", hs256(session("u-bumped", func(c jwt.MapClaims) { c["sv"] = 1 })), ""},
+		{"inactive user", hs256(session("u-inactive", nil)), ""},
+		{"unknown user", hs256(session("nobody", nil)), ""},
+		{"missing sub", hs256(session("", nil)), ""},
+		{"expired", hs256(session("u-active", func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() })), ""},
+		{"missing exp", hs256(session("u-active", func(c jwt.MapClaims) { delete(c, "exp") })), ""},
+		{"missing typ", hs256(session("u-active", func(c jwt.MapClaims) { delete(c, "typ") })), ""},
+		{"challenge token", hs256(session("u-active", func(c jwt.MapClaims) { c["typ"] = "2fa_verify" })), ""},
+		{"HS512", sign(jwt.SigningMethodHS512, []byte(testSecret), session("u-active", nil)), ""},
+		{"HS384", sign(jwt.SigningMethodHS384, []byte(testSecret), session("u-active", nil)), ""},
+		{"alg none", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, session("u-active", nil)), ""},
+		{"wrong secret", sign(jwt.SigningMethodHS256, []byte("other-secret"), session("u-active", nil)), ""},
+		{"api token", "0123456789abcdef", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseJWT(context.Background(), tt.token)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("parseJWT() = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
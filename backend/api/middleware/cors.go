@@ -1,30 +1,150 @@
 package middleware
 
 import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
 	"github.com/gin-gonic/gin"
 )
 
-// CORSMiddleware CORS 中间件
-func CORSMiddleware() gin.HandlerFunc {
+const (
+	corsDefaultMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	// X-Base-URL 已不再生效，旧版插件仍会携带，保留以免预检失败
+	corsAllowHeaders  = "Content-Type, Content-Length, Authorization, X-Base-URL, X-Request-ID"
+	corsExposeHeaders = "X-Request-ID"
+)
+
+// corsPolicy 一组来源与方法；anyOrigin 时返回 Access-Control-Allow-Origin: *
+type corsPolicy struct {
+	path      string
+	anyOrigin bool
+	origins   []*regexp.Regexp
+	methods   string
+}
+
+func newCORSPolicy(path string, origins, methods []string) corsPolicy {
+	p := corsPolicy{path: path, methods: corsDefaultMethods}
+	if len(methods) > 0 {
+		p.methods = strings.Join(methods, ", ") + ", OPTIONS"
+	}
+	for _, o := range origins {
+		if o == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		// 请求来源按小写匹配，配置中的大小写不影响结果
+		p.origins = append(p.origins, originPattern(strings.ToLower(o)))
+	}
+	return p
+}
+
+// originPattern 将来源（已通过 config.ValidateOriginPattern 校验）编译为正则：
+// :* 匹配任意端口，*. 匹配一级或多级子域名
+func originPattern(o string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(o)
+	if strings.HasSuffix(expr, `:\*`) {
+		expr = strings.TrimSuffix(expr, `:\*`) + `(:[0-9]+)?`
+	}
+	expr = strings.Replace(expr, `://\*\.`, `://([a-z0-9-]+\.)+`, 1)
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+func (p corsPolicy) allows(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	for _, re := range p.origins {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware 按配置的来源白名单处理跨域请求。
+// 路径命中 cors.routes 时使用该前缀的策略（最长前缀优先），否则使用全局白名单（含思源客户端来源）；
+// 与服务自身同源的请求始终放行。未放行的预检请求返回 403，其他请求不附带 CORS 头由浏览器拦截。
+// 路由前缀相对于 basePath 匹配
+func CORSMiddleware(cfg config.CORSConfig, basePath string) gin.HandlerFunc {
+	origins := append([]string{}, cfg.AllowedOrigins...)
+	if cfg.AllowSiYuan {
+		origins = append(origins, config.SiYuanOrigins...)
+	}
+	global := newCORSPolicy("", origins, nil)
+
+	routes := make([]corsPolicy, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes = append(routes, newCORSPolicy(r.Path, r.AllowedOrigins, r.Methods))
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return len(routes[i].path) > len(routes[j].path) })
+
+	maxAge := ""
+	if cfg.MaxAge > 0 {
+		maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+
 	return func(c *gin.Context) {
 		origin := c.GetHeader("Origin")
-		if origin != "" {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-			c.Writer.Header().Set("Vary", "Origin")
-		} else {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if origin == "" {
+			// 非浏览器跨域请求（思源内核代发、curl 等）不受 CORS 约束
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
 		}
 
-		// Bearer Token 方案通常不需要 Credentials
-		// 若未来需要携带 Cookie，可在特定路由开启：c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Authorization, X-Base-URL, X-Bootstrap-Token")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		policy := global
+		p := trimBasePath(c.Request.URL.Path, basePath)
+		for _, r := range routes {
+			if strings.HasPrefix(p, r.path) {
+				policy = r
+				break
+			}
+		}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		allowed := sameOrigin(c, origin) || policy.allows(strings.ToLower(origin))
+		if !allowed {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if policy.anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		// Bearer Token 方案不需要 Credentials，因此不发送 Access-Control-Allow-Credentials
+		h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+
+		if preflight {
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Allow-Methods", policy.methods)
+			if maxAge != "" {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// sameOrigin 来源主机与请求 Host 一致（前端页面自身发起的请求）
+func sameOrigin(c *gin.Context, origin string) bool {
+	_, host, ok := strings.Cut(origin, "://")
+	return ok && strings.EqualFold(host, c.Request.Host)
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSPolicyAllows(t *testing.T) {
+	p := newCORSPolicy("", []string{
+		"https://app.example.com",
+		"https://*.example.org",
+		"http://localhost:*",
+		"https://Mixed.Example.net",
+	}, nil)
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"http://app.example.com", false},
+		{"https://app.example.com:8443", false},
+		{"https://app.example.com.evil.io", false},
+		{"https://evilapp.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://a.example.org.evil.io", false},
+		{"https://evil.io/.example.org", false},
+		{"http://localhost", true},
+		{"http://localhost:6806", true},
+		{"http://localhost:abc", false},
+		{"http://localhost.evil.io", false},
+		{"https://mixed.example.net", true},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if got := p.allows(tt.origin); got != tt.want {
+			t.Errorf("allows(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	if !newCORSPolicy("", []string{"*"}, nil).allows("https://anything.io") {
+		t.Error(`"*" does not allow any origin`)
+	}
+	if newCORSPolicy("", nil, nil).allows("https://app.example.com") {
+		t.Error("empty allowlist allows an origin")
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowSiYuan:    true,
+		MaxAge:         600,
+		Routes: []config.CORSRoute{
+			{Path: "/api/s/", AllowedOrigins: []string{"*"}, Methods: []string{"GET"}},
+			{Path: "/api/s/private/", AllowedOrigins: []string{"https://viewer.example.com"}},
+		},
+	}
+	r := gin.New()
+	r.Use(CORSMiddleware(cfg, "/notes"))
+	r.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		origin     string
+		preflight  bool
+		wantStatus int
+		wantOrigin string // Access-Control-Allow-Origin，为空表示不返回
+		wantMethod string // 预检的 Access-Control-Allow-Methods
+	}{
+		{"allowed origin", "GET", "/notes/api/share/list", "https://app.example.com", false, 200, "https://app.example.com", ""},
+		{"allowed preflight", "OPTIONS", "/notes/api/share/create", "https://app.example.com", true, 204, "https://app.example.com", corsDefaultMethods},
+		{"siyuan client", "POST", "/notes/api/share/create", "http://127.0.0.1:6806", false, 200, "http://127.0.0.1:6806", ""},
+		{"rejected origin", "GET", "/notes/api/share/list", "https://evil.io", false, 200, "", ""},
+		{"rejected preflight", "OPTIONS", "/notes/api/share/create", "https://evil.io", true, 403, "", ""},
+		{"same origin", "GET", "/notes/api/share/list", "https://share.example.com", false, 200, "https://share.example.com", ""},
+		{"no origin preflight", "OPTIONS", "/notes/api/share/create", "", true, 204, "", ""},
+		{"route with any origin", "GET", "/notes/api/s/abc", "https://evil.io", false, 200, "*", ""},
+		{"route methods", "OPTIONS", "/notes/api/s/abc", "https://evil.io", true, 204, "*", "GET, OPTIONS"},
+		{"longest route prefix wins", "GET", "/notes/api/s/private/abc", "https://evil.io", false, 200, "", ""},
+		{"longest route prefix allowed", "GET", "/notes/api/s/private/abc", "https://viewer.example.com", false, 200, "https://viewer.example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Host = "share.example.com"
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethod {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethod)
+			}
+			if tt.wantMethod != "" && w.Header().Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want 600", w.Header().Get("Access-Control-Max-Age"))
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/logging"
+	"github.com/ZeroHawkeye/siyuan-share-api/proxy"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求 ID 头：沿用上游（反向代理）传入的值，否则生成新值，并在响应中回传
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware 为每个请求分配请求 ID，写入 gin.Context（requestID）与 request context，供日志透传
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set("requestID", id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// AccessLogMiddleware 结构化访问日志；Authorization 与查询串中的密码、令牌等敏感值会被脱敏
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"ip", proxy.ClientIP(c.Request),
+			"user_agent", c.Request.UserAgent(),
+		}
+		if q := logging.RedactQuery(c.Request.URL.RawQuery); q != "" {
+			attrs = append(attrs, "query", q)
+		}
+		if auth := logging.RedactAuthorization(c.GetHeader("Authorization")); auth != "" {
+			attrs = append(attrs, "authorization", auth)
+		}
+		if uid := c.GetString("userID"); uid != "" {
+			attrs = append(attrs, "user_id", uid)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// RecoveryMiddleware 捕获 panic 并记录到结构化日志（带请求 ID）
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "panic recovered", "error", err, "path", c.Request.URL.Path)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Internal server error"})
+	})
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
+	"github.com/ZeroHawkeye/siyuan-share-api/proxy"
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware 按 gin 路由模板记录请求数与耗时；未匹配路由（前端静态资源等）统一记为 unmatched
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTP(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// MetricsAuthMiddleware 保护 /metrics：校验 Bearer Token 或来源 IP 白名单；两者均未配置时仅允许本机访问。
+// 来源 IP 为经受信任代理解析后的客户端 IP
+func MetricsAuthMiddleware(cfg config.MetricsConfig) gin.HandlerFunc {
+	nets, _ := config.ParseIPNets(cfg.AllowedIPs) // 启动时已校验
+	return func(c *gin.Context) {
+		ip := net.ParseIP(proxy.ClientIP(c.Request))
+		allowed := false
+		if cfg.Token == "" && len(nets) == 0 {
+			allowed = ip != nil && ip.IsLoopback()
+		}
+		if cfg.Token != "" {
+			raw := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			allowed = allowed || subtle.ConstantTimeCompare([]byte(raw), []byte(cfg.Token)) == 1
+		}
+		for _, n := range nets {
+			if ip != nil && n.Contains(ip) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
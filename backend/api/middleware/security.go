@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/proxy"
+	"github.com/gin-gonic/gin"
+)
+
+// apiCSP 接口只返回 JSON，不应加载任何资源或被嵌入
+const apiCSP = "default-src 'none'; frame-ancestors 'none'"
+
+// sharePagePath 前端分享查看页 /s/:id
+var sharePagePath = regexp.MustCompile(`^/s/([A-Za-z0-9_-]{1,64})/?$`)
+
+// SecurityHeadersMiddleware 安全响应头：
+// 所有响应带 nosniff 与 Referrer-Policy，HTTPS 请求带 HSTS；
+// /api 返回禁止一切资源的 CSP，前端页面使用 security.contentSecurityPolicy。
+// 页面默认禁止被嵌入，分享查看页在分享开启 allowEmbed 时允许 security.frameAncestors 中的站点嵌入
+func SecurityHeadersMiddleware(cfg config.SecurityConfig, basePath string) gin.HandlerFunc {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(cfg.HSTSMaxAge)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+	embedAncestors := "*"
+	if len(cfg.FrameAncestors) > 0 {
+		embedAncestors = strings.Join(cfg.FrameAncestors, " ")
+	}
+	pageCSP := func(ancestors string) string {
+		if cfg.ContentSecurityPolicy == "" {
+			return "frame-ancestors " + ancestors
+		}
+		return cfg.ContentSecurityPolicy + "; frame-ancestors " + ancestors
+	}
+	denyCSP := pageCSP("'none'")
+	embedCSP := pageCSP(embedAncestors)
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if cfg.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		if hsts != "" && isHTTPS(c) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+
+		p := trimBasePath(c.Request.URL.Path, basePath)
+		switch {
+		case p == "/api" || strings.HasPrefix(p, "/api/"):
+			h.Set("Content-Security-Policy", apiCSP)
+			h.Set("X-Frame-Options", "DENY")
+		default:
+			if m := sharePagePath.FindStringSubmatch(p); m != nil && models.ShareAllowsEmbed(c.Request.Context(), m[1]) {
+				h.Set("Content-Security-Policy", embedCSP)
+			} else {
+				h.Set("Content-Security-Policy", denyCSP)
+				h.Set("X-Frame-Options", "DENY")
+			}
+		}
+		c.Next()
+	}
+}
+
+// isHTTPS 请求经 TLS 直连或由受信任的反向代理以 HTTPS 转发
+func isHTTPS(c *gin.Context) bool {
+	return proxy.Scheme(c.Request) == "https"
+}
+
+// trimBasePath 去掉挂载前缀；不在前缀下的路径原样返回
+func trimBasePath(p, basePath string) string {
+	if basePath == "" {
+		return p
+	}
+	if p == basePath {
+		return "/"
+	}
+	if strings.HasPrefix(p, basePath+"/") {
+		return p[len(basePath):]
+	}
+	return p
+}
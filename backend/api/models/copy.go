@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CopyResult 单张表的复制结果
+type CopyResult struct {
+	Table string
+	Rows  int64
+}
+
+// CopyData 将 src 中所有模型的数据（含软删除记录）复制到 dst。
+// dst 会先执行迁移；除非 force 为 true，否则要求目标库各表为空，避免与现有数据混合。
+func CopyData(src, dst *gorm.DB, batchSize int, force bool) ([]CopyResult, error) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	if _, err := MigrateUp(dst); err != nil {
+		return nil, fmt.Errorf("migrate target: %w", err)
+	}
+
+	if !force {
+		for _, m := range AllModels() {
+			var n int64
+			if err := dst.Unscoped().Model(m).Count(&n).Error; err != nil {
+				return nil, err
+			}
+			if n > 0 {
+				return nil, fmt.Errorf("target table %s is not empty (%d rows); use -force to copy anyway", tableName(dst, m), n)
+			}
+		}
+	}
+
+	results := make([]CopyResult, 0, len(AllModels()))
+	err := dst.Transaction(func(tx *gorm.DB) error {
+		for _, m := range AllModels() {
+			copied, err := copyTable(src, tx, m, batchSize)
+			if err != nil {
+				return fmt.Errorf("copy %s: %w", tableName(src, m), err)
+			}
+			results = append(results, CopyResult{Table: tableName(src, m), Rows: copied})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// copyTable 按主键排序分批复制一张表。FindInBatches 只支持单列主键，
+// team_members、share_refs 等联合主键表需按全部主键列排序后用 Limit/Offset 分页
+func copyTable(src, tx *gorm.DB, m interface{}, batchSize int) (int64, error) {
+	stmt := &gorm.Statement{DB: src}
+	if err := stmt.Parse(m); err != nil {
+		return 0, err
+	}
+	if len(stmt.Schema.PrimaryFieldDBNames) == 0 {
+		return 0, fmt.Errorf("table %s has no primary key", stmt.Schema.Table)
+	}
+	var copied int64
+	for offset := 0; ; offset += batchSize {
+		batch := reflect.New(reflect.SliceOf(reflect.TypeOf(m).Elem()))
+		q := src.Unscoped().Model(m)
+		for _, col := range stmt.Schema.PrimaryFieldDBNames {
+			q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: col}})
+		}
+		res := q.Limit(batchSize).Offset(offset).Find(batch.Interface())
+		if res.Error != nil {
+			return copied, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return copied, nil
+		}
+		// Select("*") 确保零值字段（如 is_public=false）也写入，而不是被列默认值覆盖
+		if err := tx.Session(&gorm.Session{SkipHooks: true}).Select("*").Create(batch.Interface()).Error; err != nil {
+			return copied, err
+		}
+		copied += res.RowsAffected
+		if res.RowsAffected < int64(batchSize) {
+			return copied, nil
+		}
+	}
+}
+
+func tableName(db *gorm.DB, model interface{}) string {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Sprintf("%T", model)
+	}
+	return stmt.Schema.Table
+}
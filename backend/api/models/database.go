@@ -1,82 +1,187 @@
 package models
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"strings"
 
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"github.com/ZeroHawkeye/siyuan-share-api/logging"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
 	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
+// Driver 当前数据库驱动（config.DriverSQLite / DriverPostgres / DriverMySQL）
+var Driver string
+
 // InitDB 初始化数据库连接
 func InitDB() error {
-	// 确保数据目录存在
-	dataDir := os.Getenv("DATA_DIR")
-	if dataDir == "" {
-		dataDir = "./data"
+	cfg := config.Get()
+
+	// 确保数据目录存在（SQLite 文件与其它本地数据均在此目录下）
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return err
 	}
 
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
+	target, err := config.ParseDatabaseURL(cfg.Database.URL, cfg.DataDir)
+	if err != nil {
 		return err
 	}
 
-	dbPath := filepath.Join(dataDir, "siyuan-share.db")
-	log.Printf("Database path: %s", dbPath)
+	DB, err = Open(target, cfg.Database)
+	if err != nil {
+		return err
+	}
+	Driver = target.Driver
 
-	// 配置 GORM 日志级别 (SQLITE_LOG_MODE=info|warn|silent)
-	logMode := strings.ToLower(os.Getenv("SQLITE_LOG_MODE"))
-	var gormLogger logger.Interface = logger.Default.LogMode(logger.Warn)
-	switch logMode {
-	case "info":
-		gormLogger = logger.Default.LogMode(logger.Info)
-	case "silent":
-		gormLogger = logger.Default.LogMode(logger.Silent)
-	case "warn":
-		fallthrough
-	default:
-		gormLogger = logger.Default.LogMode(logger.Warn)
+	// 检查表结构版本并执行待执行迁移
+	if err := migrateSchema(DB, cfg.Database.AutoMigrate); err != nil {
+		return err
 	}
-	config := &gorm.Config{Logger: gormLogger}
 
-	// 使用 glebarez/sqlite 驱动连接数据库
-	var err error
-	DB, err = gorm.Open(sqlite.Open(dbPath), config)
+	slog.Info("Database initialized", "driver", Driver)
+	return nil
+}
+
+// Ping 检查数据库连接是否可用（就绪探针使用）
+func Ping(ctx context.Context) error {
+	if DB == nil {
+		return errors.New("database not initialized")
+	}
+	sqlDB, err := DB.DB()
 	if err != nil {
 		return err
 	}
+	return sqlDB.PingContext(ctx)
+}
 
-	// 自动迁移数据库表结构
-	if err := autoMigrate(); err != nil {
+// Close 关闭数据库连接；SQLite 先执行 WAL checkpoint，把 -wal 文件中的数据合并回主库
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	if Driver == config.DriverSQLite {
+		if err := DB.Exec("PRAGMA wal_checkpoint(TRUNCATE);").Error; err != nil {
+			slog.Warn("SQLite WAL checkpoint failed", "error", err)
+		} else {
+			slog.Info("SQLite WAL checkpoint completed")
+		}
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
 		return err
 	}
+	return sqlDB.Close()
+}
+
+// Open 按驱动打开数据库连接；SQLite 专属的 PRAGMA 优化只在 SQLite 路径上执行
+func Open(target config.DatabaseTarget, dbCfg config.DatabaseConfig) (*gorm.DB, error) {
+	gormConfig := &gorm.Config{Logger: logging.NewGormLogger(dbCfg.LogMode)}
+	db, err := open(target, gormConfig, dbCfg)
+	if err != nil {
+		return nil, err
+	}
+	// 采集语句耗时供 /metrics 使用
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
 
-	// 性能优化 PRAGMA 设置（SQLite）
-	applySQLiteOptimizations()
+func open(target config.DatabaseTarget, gormConfig *gorm.Config, dbCfg config.DatabaseConfig) (*gorm.DB, error) {
+	switch target.Driver {
+	case config.DriverSQLite:
+		if dir := filepath.Dir(target.DSN); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+		}
+		slog.Info("Opening database", "driver", target.Driver, "path", target.DSN)
+		// 使用 glebarez/sqlite 驱动连接数据库
+		db, err := gorm.Open(sqlite.Open(target.DSN), gormConfig)
+		if err != nil {
+			return nil, err
+		}
+		// 性能优化 PRAGMA 设置（SQLite）
+		applySQLiteOptimizations(db)
+		return db, nil
 
-	log.Println("Database initialized successfully")
-	return nil
+	case config.DriverPostgres, config.DriverMySQL:
+		var dialector gorm.Dialector
+		if target.Driver == config.DriverPostgres {
+			dialector = postgres.Open(target.DSN)
+		} else {
+			dialector = mysql.Open(target.DSN)
+		}
+		slog.Info("Opening database", "driver", target.Driver)
+		db, err := gorm.Open(dialector, gormConfig)
+		if err != nil {
+			return nil, err
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		if dbCfg.MaxOpenConns > 0 {
+			sqlDB.SetMaxOpenConns(dbCfg.MaxOpenConns)
+		}
+		if dbCfg.MaxIdleConns > 0 {
+			sqlDB.SetMaxIdleConns(dbCfg.MaxIdleConns)
+		}
+		return db, nil
+	}
+	return nil, fmt.Errorf("unsupported database driver: %s", target.Driver)
 }
 
-// autoMigrate 自动迁移所有模型
-func autoMigrate() error {
-	return DB.AutoMigrate(
-		&Share{},
+// AllModels 全部持久化模型（跨库数据复制使用，按外键依赖顺序排列）。
+// 表结构由 migrations.go 中的版本化迁移维护，新增模型时需同时追加迁移。
+func AllModels() []interface{} {
+	return []interface{}{
 		&User{},
 		&UserToken{},
-		&BootstrapToken{}, // 兼容旧数据，后续可移除
-	)
+		&RecoveryCode{},
+		&Setting{},
+		&UserIdentity{},
+		&Team{},
+		&TeamMember{},
+		&Share{},
+		&BlockSnippet{},
+		&ShareRef{},
+		&ScanSettings{},
+		&PipelineSettings{},
+	}
 }
 
-// applySQLiteOptimizations 设置 SQLite 性能相关 PRAGMA
-func applySQLiteOptimizations() {
-	if DB == nil {
-		return
+// migrateSchema 拒绝比程序更新的表结构；存在待执行迁移时按 auto 决定自动执行或拒绝启动
+func migrateSchema(db *gorm.DB, auto bool) error {
+	st, err := GetMigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	if err := CheckSchemaVersion(st); err != nil {
+		return err
+	}
+	if len(st.Pending) == 0 {
+		return nil
+	}
+	if !auto {
+		return fmt.Errorf("database schema is at version %d, %d migration(s) pending; run `siyuan-share-api migrate up` or enable database.autoMigrate",
+			st.Current, len(st.Pending))
 	}
+	_, err = MigrateUp(db)
+	return err
+}
+
+// applySQLiteOptimizations 设置 SQLite 性能相关 PRAGMA
+func applySQLiteOptimizations(db *gorm.DB) {
 	// 仅在本地或单实例下开启 WAL 等优化
 	pragmas := []string{
 		"PRAGMA journal_mode=WAL;",
@@ -86,8 +191,8 @@ func applySQLiteOptimizations() {
 		"PRAGMA wal_autocheckpoint=2000;",
 	}
 	for _, p := range pragmas {
-		if err := DB.Exec(p).Error; err != nil {
-			log.Printf("SQLite PRAGMA failed (%s): %v", p, err)
+		if err := db.Exec(p).Error; err != nil {
+			slog.Warn("SQLite PRAGMA failed", "pragma", p, "error", err)
 		}
 	}
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UserIdentity 外部身份提供方（OIDC）账号与本地用户的绑定
+type UserIdentity struct {
+	ID          string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID      string     `gorm:"index;size:64" json:"userId"`
+	Issuer      string     `gorm:"size:255;uniqueIndex:idx_identity_issuer_subject,priority:1" json:"issuer"`
+	Subject     string     `gorm:"size:255;uniqueIndex:idx_identity_issuer_subject,priority:2" json:"subject"`
+	Email       string     `gorm:"size:255" json:"email"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func (UserIdentity) TableName() string { return "user_identities" }
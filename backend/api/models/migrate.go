@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Migration 一个版本化的数据库迁移步骤。
+// Up/Down 只能引用迁移内部冻结的结构体或原始 SQL，不能引用会随代码演进的业务模型。
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"size:255" json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// ErrSchemaTooNew 数据库版本高于当前程序支持的最新版本（通常是回滚到了旧版程序）
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Current int // 数据库当前版本（0 表示未执行过任何迁移）
+	Latest  int // 程序内置的最新版本
+	Applied []SchemaMigration
+	Pending []Migration // 待执行的迁移
+}
+
+// DryRunStep 演练结果：某个迁移将执行的 SQL
+type DryRunStep struct {
+	Migration Migration
+	SQL       []string
+	Executed  bool // false 表示该驱动不支持事务性 DDL，仅列出未演练
+}
+
+// Migrations 返回按版本排序的全部迁移
+func Migrations() []Migration {
+	list := append([]Migration(nil), migrations...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// LatestSchemaVersion 程序支持的最新版本
+func LatestSchemaVersion() int {
+	list := Migrations()
+	if len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+// GetMigrationStatus 读取数据库当前版本与待执行迁移
+func GetMigrationStatus(db *gorm.DB) (*MigrationStatus, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	var applied []SchemaMigration
+	if err := db.Order("version ASC").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(applied))
+	st := &MigrationStatus{Latest: LatestSchemaVersion(), Applied: applied}
+	for _, a := range applied {
+		done[a.Version] = true
+		if a.Version > st.Current {
+			st.Current = a.Version
+		}
+	}
+	for _, m := range Migrations() {
+		if !done[m.Version] {
+			st.Pending = append(st.Pending, m)
+		}
+	}
+	return st, nil
+}
+
+// CheckSchemaVersion 拒绝在比程序更新的数据库上运行
+func CheckSchemaVersion(st *MigrationStatus) error {
+	if st.Current > st.Latest {
+		return fmt.Errorf("%w: database is at version %d, latest known is %d; upgrade the binary or run `migrate down` with the newer binary",
+			ErrSchemaTooNew, st.Current, st.Latest)
+	}
+	return nil
+}
+
+// MigrateUp 按顺序执行全部待执行迁移，每个迁移在独立事务中执行并记录版本
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	var applied []Migration
+	err := withMigrationLock(db, func(conn *gorm.DB) error {
+		st, err := GetMigrationStatus(conn)
+		if err != nil {
+			return err
+		}
+		if err := CheckSchemaVersion(st); err != nil {
+			return err
+		}
+		for _, m := range st.Pending {
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+			}
+			slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown 回滚最近执行的 steps 个迁移
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	byVersion := map[int]Migration{}
+	for _, m := range Migrations() {
+		byVersion[m.Version] = m
+	}
+	var reverted []Migration
+	err := withMigrationLock(db, func(conn *gorm.DB) error {
+		st, err := GetMigrationStatus(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(st.Applied) - 1; i >= 0 && len(reverted) < steps; i-- {
+			a := st.Applied[i]
+			m, ok := byVersion[a.Version]
+			if !ok || m.Down == nil {
+				return fmt.Errorf("migration %d (%s) cannot be reverted by this binary", a.Version, a.Name)
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("revert migration %d (%s) failed: %w", m.Version, m.Name, err)
+			}
+			slog.Info("Reverted migration", "version", m.Version, "name", m.Name)
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// DryRunMigrations 演练待执行迁移：支持事务性 DDL 的驱动（SQLite、PostgreSQL）在事务中执行并回滚，
+// 记录将要执行的 SQL；MySQL 的 DDL 会隐式提交，只列出迁移而不执行。
+func DryRunMigrations(db *gorm.DB, driver string) ([]DryRunStep, error) {
+	st, err := GetMigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckSchemaVersion(st); err != nil {
+		return nil, err
+	}
+	if driver == config.DriverMySQL {
+		steps := make([]DryRunStep, 0, len(st.Pending))
+		for _, m := range st.Pending {
+			steps = append(steps, DryRunStep{Migration: m})
+		}
+		return steps, nil
+	}
+
+	rec := &sqlRecorder{Interface: logger.Discard}
+	var steps []DryRunStep
+	errRollback := errors.New("dry run rollback")
+	err = db.Session(&gorm.Session{Logger: rec}).Transaction(func(tx *gorm.DB) error {
+		for _, m := range st.Pending {
+			rec.statements = nil
+			if err := m.Up(tx); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+			}
+			steps = append(steps, DryRunStep{Migration: m, SQL: rec.statements, Executed: true})
+		}
+		return errRollback
+	})
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// withMigrationLock 在单个连接上持有跨进程锁执行迁移，避免多副本同时启动时重复迁移
+func withMigrationLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		switch conn.Dialector.Name() {
+		case "postgres":
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID)
+		case "mysql":
+			var got int
+			if err := conn.Raw("SELECT GET_LOCK(?, ?)", "siyuan_share_migrate", 60).Scan(&got).Error; err != nil {
+				return err
+			}
+			if got != 1 {
+				return errors.New("timed out waiting for migration lock")
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", "siyuan_share_migrate")
+		}
+		// Connection 返回的实例不可安全复用（链式调用会累积语句状态），开新会话再交给调用方
+		return fn(conn.Session(&gorm.Session{}))
+	})
+}
+
+const migrationLockID = 7274830031
+
+// sqlRecorder 记录执行过的变更 SQL（演练用），忽略迁移器内部的结构查询
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface { return r }
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	head := strings.ToUpper(strings.TrimSpace(sql))
+	for _, p := range []string{"SELECT", "PRAGMA", "SHOW"} {
+		if strings.HasPrefix(head, p) {
+			return
+		}
+	}
+	r.statements = append(r.statements, sql)
+}
@@ -0,0 +1,689 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations 全部版本化迁移。新增或修改表结构时追加新版本，不要修改已发布的迁移。
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up: func(tx *gorm.DB) error {
+			// 对 AutoMigrate 时代创建的旧库同样适用：已存在的表只会补齐缺失的列与索引
+			return tx.AutoMigrate(v1Tables()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(v1Tables()...)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "drop legacy bootstrap_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v1BootstrapToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v1BootstrapToken{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add shares.allow_embed",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&v3Share{}, "AllowEmbed")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&v3Share{}, "AllowEmbed")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add share_refs reference index",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&v4ShareRef{}); err != nil {
+				return err
+			}
+			return backfillShareRefs(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v4ShareRef{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "move block shares to block_snippets",
+		Up:      upBlockSnippets,
+		Down:    downBlockSnippets,
+	},
+	{
+		Version: 6,
+		Name:    "add nested block reference columns",
+		Up: func(tx *gorm.DB) error {
+			// 已有引用均视为文档直接引用（depth 默认 1）
+			if err := tx.Migrator().AddColumn(&v6BlockSnippet{}, "References"); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&v6ShareRef{}, "Depth")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&v6ShareRef{}, "Depth"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v6BlockSnippet{}, "References")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add shares.anchors",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&v7Share{}, "Anchors")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&v7Share{}, "Anchors")
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add scan_settings",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v8ScanSettings{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v8ScanSettings{})
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add transformer pipelines",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&v9PipelineSettings{}); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&v9Share{}, "Pipeline")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&v9Share{}, "Pipeline"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&v9PipelineSettings{})
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add second factor lockout columns",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v10User{}, "TOTPFailures"); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&v10User{}, "TOTPLockedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&v10User{}, "TOTPLockedAt"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v10User{}, "TOTPFailures")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "widen mysql share text columns",
+		Up: func(tx *gorm.DB) error {
+			return alterLargeTextColumns(tx, &v11Share{}, &v11BlockSnippet{})
+		},
+		Down: func(tx *gorm.DB) error {
+			// 严格模式下超过 64 KB 的内容会使回滚失败，而不是被截断
+			return alterLargeTextColumns(tx, &v10Share{}, &v10BlockSnippet{})
+		},
+	},
+	{
+		Version: 12,
+		Name:    "add users.session_version",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&v12User{}, "SessionVersion")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&v12User{}, "SessionVersion")
+		},
+	},
+}
+
+// v1Tables 版本 1 的表结构（冻结副本，不随业务模型变化）
+func v1Tables() []interface{} {
+	return []interface{}{
+		&v1User{},
+		&v1UserToken{},
+		&v1RecoveryCode{},
+		&v1Setting{},
+		&v1UserIdentity{},
+		&v1Team{},
+		&v1TeamMember{},
+		&v1Share{},
+		&v1BootstrapToken{},
+	}
+}
+
+type v1User struct {
+	ID              string `gorm:"primaryKey;size:64"`
+	Username        string `gorm:"size:100;uniqueIndex"`
+	Email           string `gorm:"size:255;uniqueIndex"`
+	PasswordHash    string `gorm:"size:255"`
+	IsActive        bool   `gorm:"default:true"`
+	IsAdmin         bool   `gorm:"default:false"`
+	TOTPSecret      string `gorm:"size:64"`
+	TOTPEnabled     bool   `gorm:"default:false"`
+	TOTPLastCounter int64  `gorm:"default:0"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1UserToken struct {
+	ID         string `gorm:"primaryKey;size:64"`
+	UserID     string `gorm:"index;size:64"`
+	Name       string `gorm:"size:100"`
+	TokenHash  string `gorm:"size:255;uniqueIndex"`
+	Revoked    bool   `gorm:"default:false"`
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1UserToken) TableName() string { return "user_tokens" }
+
+type v1RecoveryCode struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	UserID    string `gorm:"index;size:64"`
+	CodeHash  string `gorm:"size:255;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (v1RecoveryCode) TableName() string { return "recovery_codes" }
+
+type v1Setting struct {
+	Name      string `gorm:"primaryKey;size:100"`
+	Value     string `gorm:"type:text"`
+	UpdatedAt time.Time
+}
+
+func (v1Setting) TableName() string { return "settings" }
+
+type v1UserIdentity struct {
+	ID          string `gorm:"primaryKey;size:64"`
+	UserID      string `gorm:"index;size:64"`
+	Issuer      string `gorm:"size:255;uniqueIndex:idx_identity_issuer_subject,priority:1"`
+	Subject     string `gorm:"size:255;uniqueIndex:idx_identity_issuer_subject,priority:2"`
+	Email       string `gorm:"size:255"`
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (v1UserIdentity) TableName() string { return "user_identities" }
+
+type v1Team struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Name      string `gorm:"size:100"`
+	CreatedBy string `gorm:"size:64"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Team) TableName() string { return "teams" }
+
+type v1TeamMember struct {
+	TeamID    string `gorm:"primaryKey;size:64"`
+	UserID    string `gorm:"primaryKey;size:64;index"`
+	Role      string `gorm:"size:20"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v1TeamMember) TableName() string { return "team_members" }
+
+type v1Share struct {
+	ID              string    `gorm:"primaryKey;size:64"`
+	UserID          string    `gorm:"size:64;index:idx_user_doc,priority:1;index:idx_user_created,priority:1"`
+	DocID           string    `gorm:"size:64;index:idx_user_doc,priority:2;index:idx_team_doc,priority:2"`
+	TeamID          string    `gorm:"size:64;default:'';index:idx_team_doc,priority:1"`
+	DocTitle        string    `gorm:"size:255"`
+	Content         string    `gorm:"type:text"`
+	References      string    `gorm:"type:text"`
+	ParentShareID   string    `gorm:"size:64;index"`
+	RequirePassword bool      `gorm:"default:false"`
+	PasswordHash    string    `gorm:"size:255"`
+	ExpireAt        time.Time `gorm:"index"`
+	IsPublic        bool      `gorm:"default:true"`
+	ViewCount       int       `gorm:"default:0"`
+	CreatedAt       time.Time `gorm:"index:idx_user_created,priority:2"`
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Share) TableName() string { return "shares" }
+
+type v1BootstrapToken struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Token     string `gorm:"size:255;uniqueIndex"`
+	ExpiresAt time.Time
+	Used      bool `gorm:"default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v1BootstrapToken) TableName() string { return "bootstrap_tokens" }
+
+// v3Share 版本 3 新增的分享列
+type v3Share struct {
+	AllowEmbed bool `gorm:"default:false"`
+}
+
+func (v3Share) TableName() string { return "shares" }
+
+// v4ShareRef 版本 4 的引用索引表
+type v4ShareRef struct {
+	ParentShareID string `gorm:"primaryKey;size:64"`
+	BlockID       string `gorm:"primaryKey;size:64"`
+	BlockShareID  string `gorm:"size:64;index"`
+	CreatedAt     time.Time
+}
+
+func (v4ShareRef) TableName() string { return "share_refs" }
+
+// backfillShareRefs 按已发布分享的 references 与同一所有者最新的引用块分享建立索引
+func backfillShareRefs(tx *gorm.DB) error {
+	type parentRow struct {
+		ID         string
+		UserID     string
+		TeamID     string
+		References string
+	}
+	var parents []parentRow
+	if err := tx.Table("shares").
+		Select("id, user_id, team_id, " + tx.Statement.Quote("references")).
+		Where("deleted_at IS NULL AND parent_share_id = '' AND " + tx.Statement.Quote("references") + " <> ''").
+		Find(&parents).Error; err != nil {
+		return err
+	}
+	for _, p := range parents {
+		var refs []struct {
+			BlockID string `json:"blockId"`
+		}
+		if json.Unmarshal([]byte(p.References), &refs) != nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, r := range refs {
+			if r.BlockID == "" || seen[r.BlockID] {
+				continue
+			}
+			seen[r.BlockID] = true
+			q := tx.Table("shares").Where("deleted_at IS NULL AND doc_id = ?", r.BlockID)
+			if p.TeamID != "" {
+				q = q.Where("team_id = ?", p.TeamID)
+			} else {
+				q = q.Where("user_id = ? AND team_id = ?", p.UserID, "")
+			}
+			var ids []string
+			if err := q.Order("created_at DESC").Limit(1).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			if err := tx.Create(&v4ShareRef{ParentShareID: p.ID, BlockID: r.BlockID, BlockShareID: ids[0]}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// v5BlockSnippet 版本 5 的引用块片段表
+type v5BlockSnippet struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	UserID    string `gorm:"size:64;uniqueIndex:idx_snippet_owner_block,priority:1"`
+	TeamID    string `gorm:"size:64;default:'';uniqueIndex:idx_snippet_owner_block,priority:2"`
+	BlockID   string `gorm:"size:64;uniqueIndex:idx_snippet_owner_block,priority:3"`
+	Title     string `gorm:"size:255"`
+	Content   string `gorm:"type:text"`
+	ViewCount int    `gorm:"default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v5BlockSnippet) TableName() string { return "block_snippets" }
+
+// v5ShareRef 版本 5 的引用表：block_share_id 更名为 snippet_id，指向 block_snippets
+type v5ShareRef struct {
+	ParentShareID string `gorm:"primaryKey;size:64"`
+	BlockID       string `gorm:"primaryKey;size:64"`
+	SnippetID     string `gorm:"size:64;index"`
+	CreatedAt     time.Time
+}
+
+func (v5ShareRef) TableName() string { return "share_refs" }
+
+// v5ChildShare 版本 5 之前以分享记录保存的引用块（shares.parent_share_id 非空）
+type v5ChildShare struct {
+	ID            string
+	UserID        string
+	TeamID        string
+	DocID         string
+	DocTitle      string
+	Content       string
+	ParentShareID string
+	ViewCount     int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// upBlockSnippets 将引用块分享迁移为片段：同一所有者同一块的多条记录合并为最新的一条（保留其 ID，浏览次数累加），
+// 引用改为指向片段，没有未删除父分享引用的记录不再保留；最后删除这些分享记录与 shares.parent_share_id 列
+func upBlockSnippets(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(&v5BlockSnippet{}); err != nil {
+		return err
+	}
+
+	var children []v5ChildShare
+	if err := tx.Table("shares").
+		Select("id, user_id, team_id, doc_id, doc_title, content, parent_share_id, view_count, created_at, updated_at").
+		Where("deleted_at IS NULL AND parent_share_id <> ''").
+		Order("created_at DESC").
+		Find(&children).Error; err != nil {
+		return err
+	}
+
+	// 旧分享 ID → 合并后的片段 ID
+	mapped := make(map[string]string, len(children))
+	kept := map[[3]string]*v5BlockSnippet{}
+	var snippets []*v5BlockSnippet
+	for _, ch := range children {
+		owner := [3]string{ch.UserID, "", ch.DocID}
+		if ch.TeamID != "" {
+			owner = [3]string{"", ch.TeamID, ch.DocID}
+		}
+		if sn := kept[owner]; sn != nil {
+			sn.ViewCount += ch.ViewCount
+			mapped[ch.ID] = sn.ID
+			continue
+		}
+		sn := &v5BlockSnippet{ID: ch.ID, UserID: owner[0], TeamID: owner[1], BlockID: ch.DocID,
+			Title: ch.DocTitle, Content: ch.Content, ViewCount: ch.ViewCount, CreatedAt: ch.CreatedAt, UpdatedAt: ch.UpdatedAt}
+		kept[owner] = sn
+		snippets = append(snippets, sn)
+		mapped[ch.ID] = sn.ID
+	}
+	if len(snippets) > 0 {
+		if err := tx.CreateInBatches(snippets, 200).Error; err != nil {
+			return err
+		}
+	}
+
+	// 引用表改为指向片段：保留父分享未删除且目标已迁移的引用，补齐仅记录在 parent_share_id 中的引用
+	var refs []v4ShareRef
+	if err := tx.Table("share_refs").
+		Joins("JOIN shares ON shares.id = share_refs.parent_share_id AND shares.deleted_at IS NULL").
+		Select("share_refs.parent_share_id, share_refs.block_id, share_refs.block_share_id, share_refs.created_at").
+		Find(&refs).Error; err != nil {
+		return err
+	}
+	var liveParents []string
+	if err := tx.Table("shares").Where("deleted_at IS NULL AND parent_share_id = ''").Pluck("id", &liveParents).Error; err != nil {
+		return err
+	}
+	live := make(map[string]bool, len(liveParents))
+	for _, id := range liveParents {
+		live[id] = true
+	}
+	links := map[[2]string]v5ShareRef{}
+	for _, r := range refs {
+		if id, ok := mapped[r.BlockShareID]; ok {
+			links[[2]string{r.ParentShareID, r.BlockID}] = v5ShareRef{ParentShareID: r.ParentShareID, BlockID: r.BlockID, SnippetID: id, CreatedAt: r.CreatedAt}
+		}
+	}
+	for _, ch := range children {
+		key := [2]string{ch.ParentShareID, ch.DocID}
+		if _, ok := links[key]; !ok && live[ch.ParentShareID] {
+			links[key] = v5ShareRef{ParentShareID: ch.ParentShareID, BlockID: ch.DocID, SnippetID: mapped[ch.ID], CreatedAt: ch.CreatedAt}
+		}
+	}
+
+	m := tx.Migrator()
+	if err := m.DropTable(&v4ShareRef{}); err != nil {
+		return err
+	}
+	if err := m.CreateTable(&v5ShareRef{}); err != nil {
+		return err
+	}
+	rows := make([]v5ShareRef, 0, len(links))
+	for _, l := range links {
+		rows = append(rows, l)
+	}
+	if len(rows) > 0 {
+		if err := tx.CreateInBatches(rows, 200).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("NOT EXISTS (SELECT 1 FROM share_refs WHERE share_refs.snippet_id = block_snippets.id)").
+		Delete(&v5BlockSnippet{}).Error; err != nil {
+		return err
+	}
+
+	// 引用块分享记录（含已软删除的）已迁移或失效，连同 parent_share_id 列一并删除
+	if err := tx.Exec("DELETE FROM shares WHERE parent_share_id <> ''").Error; err != nil {
+		return err
+	}
+	if m.HasIndex(&v1Share{}, "ParentShareID") {
+		if err := m.DropIndex(&v1Share{}, "ParentShareID"); err != nil {
+			return err
+		}
+	}
+	return m.DropColumn(&v1Share{}, "ParentShareID")
+}
+
+// downBlockSnippets 恢复为引用块分享记录：每个片段恢复为一条分享（保留 ID），
+// 父分享、密码与过期时间取引用它的最新父分享；多个父分享共用的片段只能归属其中一个
+func downBlockSnippets(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if err := m.AddColumn(&v1Share{}, "ParentShareID"); err != nil {
+		return err
+	}
+	if err := m.CreateIndex(&v1Share{}, "ParentShareID"); err != nil {
+		return err
+	}
+
+	var snippets []v5BlockSnippet
+	if err := tx.Find(&snippets).Error; err != nil {
+		return err
+	}
+	var refs []v5ShareRef
+	if err := tx.Find(&refs).Error; err != nil {
+		return err
+	}
+	for _, sn := range snippets {
+		var parent struct {
+			ID              string
+			UserID          string
+			TeamID          string
+			RequirePassword bool
+			PasswordHash    string
+			ExpireAt        time.Time
+			IsPublic        bool
+			AllowEmbed      bool
+		}
+		err := tx.Table("shares").
+			Select("shares.id, shares.user_id, shares.team_id, shares.require_password, shares.password_hash, shares.expire_at, shares.is_public, shares.allow_embed").
+			Joins("JOIN share_refs ON share_refs.parent_share_id = shares.id").
+			Where("share_refs.snippet_id = ? AND shares.deleted_at IS NULL", sn.ID).
+			Order("shares.updated_at DESC").
+			Limit(1).Scan(&parent).Error
+		if err != nil {
+			return err
+		}
+		if parent.ID == "" {
+			continue
+		}
+		if err := tx.Table("shares").Create(map[string]interface{}{
+			"id":               sn.ID,
+			"user_id":          parent.UserID,
+			"team_id":          parent.TeamID,
+			"doc_id":           sn.BlockID,
+			"doc_title":        sn.Title,
+			"content":          sn.Content,
+			"references":       "",
+			"parent_share_id":  parent.ID,
+			"require_password": parent.RequirePassword,
+			"password_hash":    parent.PasswordHash,
+			"expire_at":        parent.ExpireAt,
+			"is_public":        parent.IsPublic,
+			"allow_embed":      parent.AllowEmbed,
+			"view_count":       sn.ViewCount,
+			"created_at":       sn.CreatedAt,
+			"updated_at":       sn.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := m.DropTable(&v5ShareRef{}); err != nil {
+		return err
+	}
+	if err := m.CreateTable(&v4ShareRef{}); err != nil {
+		return err
+	}
+	for _, r := range refs {
+		if err := tx.Create(&v4ShareRef{ParentShareID: r.ParentShareID, BlockID: r.BlockID, BlockShareID: r.SnippetID, CreatedAt: r.CreatedAt}).Error; err != nil {
+			return err
+		}
+	}
+	return m.DropTable(&v5BlockSnippet{})
+}
+
+// v6BlockSnippet 版本 6 新增的片段列
+type v6BlockSnippet struct {
+	References string `gorm:"type:text"`
+}
+
+func (v6BlockSnippet) TableName() string { return "block_snippets" }
+
+// v6ShareRef 版本 6 新增的引用列
+type v6ShareRef struct {
+	Depth int `gorm:"default:1"`
+}
+
+func (v6ShareRef) TableName() string { return "share_refs" }
+
+// v7Share 版本 7 新增的分享列
+type v7Share struct {
+	Anchors string `gorm:"type:text"`
+}
+
+func (v7Share) TableName() string { return "shares" }
+
+// v8ScanSettings 版本 8 的用户检测设置表
+type v8ScanSettings struct {
+	UserID        string `gorm:"primaryKey;size:64"`
+	Mode          string `gorm:"size:16"`
+	CustomRules   string `gorm:"type:text"`
+	Allowlist     string `gorm:"type:text"`
+	DisabledRules string `gorm:"type:text"`
+	UpdatedAt     time.Time
+}
+
+func (v8ScanSettings) TableName() string { return "scan_settings" }
+
+// v9PipelineSettings 版本 9 的用户流水线设置表
+type v9PipelineSettings struct {
+	UserID    string `gorm:"primaryKey;size:64"`
+	Pipeline  string `gorm:"type:text"`
+	UpdatedAt time.Time
+}
+
+func (v9PipelineSettings) TableName() string { return "pipeline_settings" }
+
+// v9Share 版本 9 新增的分享列
+type v9Share struct {
+	Pipeline string `gorm:"type:text"`
+}
+
+func (v9Share) TableName() string { return "shares" }
+
+// v10User 版本 10 新增的用户列
+type v10User struct {
+	TOTPFailures int `gorm:"default:0"`
+	TOTPLockedAt *time.Time
+}
+
+func (v10User) TableName() string { return "users" }
+
+// v10Share 版本 11 之前的分享列类型，回滚用
+type v10Share struct {
+	Content    string `gorm:"type:text"`
+	References string `gorm:"type:text"`
+	Anchors    string `gorm:"type:text"`
+}
+
+func (v10Share) TableName() string { return "shares" }
+
+// v10BlockSnippet 版本 11 之前的引用块片段列类型，回滚用
+type v10BlockSnippet struct {
+	Content    string `gorm:"type:text"`
+	References string `gorm:"type:text"`
+}
+
+func (v10BlockSnippet) TableName() string { return "block_snippets" }
+
+// v11Share 版本 11 在 MySQL 上加宽的分享列：TEXT 最多 64 KB，正文与引用块 JSON 改用 LONGTEXT
+type v11Share struct {
+	Content    string `gorm:"type:longtext"`
+	References string `gorm:"type:longtext"`
+	Anchors    string `gorm:"type:longtext"`
+}
+
+func (v11Share) TableName() string { return "shares" }
+
+// v11BlockSnippet 版本 11 在 MySQL 上加宽的引用块片段列
+type v11BlockSnippet struct {
+	Content    string `gorm:"type:longtext"`
+	References string `gorm:"type:longtext"`
+}
+
+func (v11BlockSnippet) TableName() string { return "block_snippets" }
+
+// largeTextColumns 版本 11 加宽的列
+var largeTextColumns = map[string][]string{
+	"shares":         {"Content", "References", "Anchors"},
+	"block_snippets": {"Content", "References"},
+}
+
+// alterLargeTextColumns 仅在 MySQL 上按 models 中的类型修改 largeTextColumns 的列类型；
+// SQLite 与 PostgreSQL 的 TEXT 没有长度限制，不做处理
+func alterLargeTextColumns(tx *gorm.DB, models ...interface{}) error {
+	if tx.Dialector.Name() != "mysql" {
+		return nil
+	}
+	for _, m := range models {
+		for _, col := range largeTextColumns[tableName(tx, m)] {
+			if err := tx.Migrator().AlterColumn(m, col); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// v12User 版本 12 新增的用户列
+type v12User struct {
+	SessionVersion int `gorm:"default:0"`
+}
+
+func (v12User) TableName() string { return "users" }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PipelineSettings 用户的内容转换流水线，未设置的阶段使用服务端默认
+type PipelineSettings struct {
+	UserID    string    `gorm:"primaryKey;size:64" json:"-"`
+	Pipeline  string    `gorm:"type:text" json:"-"` // JSON transform.Pipeline
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (PipelineSettings) TableName() string { return "pipeline_settings" }
+
+// GetPipelineSettings 读取用户的流水线设置，未设置时返回空设置
+func GetPipelineSettings(db *gorm.DB, userID string) (*PipelineSettings, error) {
+	var s PipelineSettings
+	err := db.Where("user_id = ?", userID).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &PipelineSettings{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScanSettings 用户的发布前敏感信息检测设置，未设置时使用 publish.scanMode 与全部内置规则
+type ScanSettings struct {
+	UserID        string    `gorm:"primaryKey;size:64" json:"-"`
+	Mode          string    `gorm:"size:16" json:"mode"` // off / warn / block / redact，为空使用 publish.scanMode
+	CustomRules   string    `gorm:"type:text" json:"-"`  // JSON []ScanRule：自定义正则规则
+	Allowlist     string    `gorm:"type:text" json:"-"`  // JSON []string：匹配这些正则的内容不报告
+	DisabledRules string    `gorm:"type:text" json:"-"`  // JSON []string：停用的内置规则 ID
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func (ScanSettings) TableName() string { return "scan_settings" }
+
+// ScanRule 用户自定义检测规则
+type ScanRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// GetScanSettings 读取用户的检测设置，未设置时返回空设置
+func GetScanSettings(db *gorm.DB, userID string) (*ScanSettings, error) {
+	var s ScanSettings
+	err := db.Where("user_id = ?", userID).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &ScanSettings{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
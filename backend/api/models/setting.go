@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 系统设置键
+const (
+	// SettingRequire2FA 管理员策略：要求所有用户启用双因素认证后才能登录 Web
+	SettingRequire2FA = "security.require_2fa"
+)
+
+// Setting 系统级键值设置（由管理员维护）
+type Setting struct {
+	Name      string    `gorm:"primaryKey;size:100" json:"name"`
+	Value     string    `gorm:"type:text" json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (Setting) TableName() string { return "settings" }
+
+// GetSetting 读取设置，不存在时返回默认值
+func GetSetting(key, def string) (string, error) {
+	var s Setting
+	err := DB.Where("name = ?", key).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	return s.Value, nil
+}
+
+// GetBoolSetting 读取布尔设置，无法解析时返回默认值
+func GetBoolSetting(key string, def bool) bool {
+	v, err := GetSetting(key, "")
+	if err != nil || v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// SetSetting 写入或覆盖设置
+func SetSetting(key, value string) error {
+	return DB.Save(&Setting{Name: key, Value: value}).Error
+}
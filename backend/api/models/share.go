@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -12,15 +13,18 @@ type Share struct {
 	ID string `gorm:"primaryKey;size:64" json:"id"`
 	// 组合索引加速 user+doc 查询与分页，并支持按创建时间排序
 	UserID          string         `gorm:"size:64;index:idx_user_doc,priority:1;index:idx_user_created,priority:1" json:"userId"`
-	DocID           string         `gorm:"size:64;index:idx_user_doc,priority:2" json:"docId"`
+	DocID           string         `gorm:"size:64;index:idx_user_doc,priority:2;index:idx_team_doc,priority:2" json:"docId"`
+	TeamID          string         `gorm:"size:64;default:'';index:idx_team_doc,priority:1" json:"teamId"` // 所属团队，为空表示个人分享
 	DocTitle        string         `gorm:"size:255" json:"docTitle"`
-	Content         string         `gorm:"type:text" json:"content"`
-	References      string         `gorm:"type:text" json:"references"`        // JSON 字符串存储引用块信息
-	ParentShareID   string         `gorm:"size:64;index" json:"parentShareId"` // 父分享ID(引用块分享时使用)
+	Content         string         `gorm:"type:text" json:"content"`    // MySQL 上 content、references、anchors 为 LONGTEXT（迁移 11）
+	References      string         `gorm:"type:text" json:"references"` // JSON 字符串存储引用块信息
+	Anchors         string         `gorm:"type:text" json:"anchors"`    // JSON 对象：同一文档内的块 ID → 页面锚点
+	Pipeline        string         `gorm:"type:text" json:"-"`          // JSON transform.Pipeline：分享的内容转换流水线，为空继承用户设置
 	RequirePassword bool           `gorm:"default:false" json:"requirePassword"`
 	PasswordHash    string         `gorm:"size:255" json:"-"` // 不在 JSON 中暴露
 	ExpireAt        time.Time      `gorm:"index" json:"expireAt"`
 	IsPublic        bool           `gorm:"default:true" json:"isPublic"`
+	AllowEmbed      bool           `gorm:"default:false" json:"allowEmbed"` // 允许被其他站点以 iframe 嵌入
 	ViewCount       int            `gorm:"default:0" json:"viewCount"`
 	CreatedAt       time.Time      `gorm:"index:idx_user_created,priority:2" json:"createdAt"`
 	UpdatedAt       time.Time      `json:"updatedAt"`
@@ -45,10 +49,21 @@ func (s *Share) IsExpired() bool {
 	return time.Now().After(s.ExpireAt)
 }
 
-// FindActiveShareByDoc 查找用户某个文档的最新有效分享（未删除）
-func FindActiveShareByDoc(userID, docID string) (*Share, error) {
+// OwnedBy 按所有者限定查询范围：teamID 非空时为团队分享，否则为 userID 的个人分享
+func OwnedBy(userID, teamID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if teamID != "" {
+			return db.Where("team_id = ?", teamID)
+		}
+		return db.Where("user_id = ? AND team_id = ?", userID, "")
+	}
+}
+
+// FindActiveShareByDoc 查找某个所有者（个人或团队）对某个文档的最新有效分享（未删除）
+func FindActiveShareByDoc(userID, teamID, docID string) (*Share, error) {
 	var share Share
-	err := DB.Where("user_id = ? AND doc_id = ?", userID, docID).
+	err := DB.Scopes(OwnedBy(userID, teamID)).
+		Where("doc_id = ?", docID).
 		Order("created_at DESC").
 		First(&share).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -60,8 +75,109 @@ func FindActiveShareByDoc(userID, docID string) (*Share, error) {
 	return &share, nil
 }
 
-// DeleteSharesByUser 删除用户的全部分享
+// CanEditShare 判断用户能否更新/删除分享：个人分享仅限本人，团队分享限所有者与编辑者
+func CanEditShare(userID string, s *Share) (bool, error) {
+	if s.TeamID == "" {
+		return s.UserID == userID, nil
+	}
+	role, err := GetTeamRole(s.TeamID, userID)
+	if err != nil {
+		return false, err
+	}
+	return CanEditTeamShares(role), nil
+}
+
+// CanViewShare 判断用户能否查看分享的管理信息：个人分享仅限本人，团队分享限团队成员
+func CanViewShare(userID string, s *Share) (bool, error) {
+	if s.TeamID == "" {
+		return s.UserID == userID, nil
+	}
+	role, err := GetTeamRole(s.TeamID, userID)
+	if err != nil {
+		return false, err
+	}
+	return role != "", nil
+}
+
+// DeleteShares 删除分享并移除其块引用（不再被引用的片段随之删除），返回受影响的片段 ID
+func DeleteShares(db *gorm.DB, ids ...string) ([]string, error) {
+	var affected []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ?", ids).Delete(&Share{}).Error; err != nil {
+			return err
+		}
+		var err error
+		affected, err = DetachShares(tx, ids...)
+		return err
+	})
+	return affected, err
+}
+
+// DeleteSharesByUser 删除用户的全部个人分享（不含团队分享）及其块引用
 func DeleteSharesByUser(userID string) (int64, error) {
-	res := DB.Where("user_id = ?", userID).Delete(&Share{})
-	return res.RowsAffected, res.Error
+	var count int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		if err := tx.Model(&Share{}).Scopes(OwnedBy(userID, "")).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		res := tx.Where("id IN ?", ids).Delete(&Share{})
+		if res.Error != nil {
+			return res.Error
+		}
+		count = res.RowsAffected
+		_, err := DetachShares(tx, ids...)
+		return err
+	})
+	return count, err
+}
+
+// CountShareStats 统计未过期分享数与已过期待清理分享数（供监控指标使用）
+func CountShareStats() (active, expired int64, err error) {
+	now := time.Now()
+	if err = DB.Model(&Share{}).Where("expire_at > ?", now).Count(&active).Error; err != nil {
+		return
+	}
+	err = DB.Model(&Share{}).Where("expire_at <= ?", now).Count(&expired).Error
+	return
+}
+
+// ShareAllowsEmbed 分享是否允许被 iframe 嵌入（不存在或已删除时为 false）。
+// 引用块片段在任一未过期的父分享允许嵌入时允许
+func ShareAllowsEmbed(ctx context.Context, id string) bool {
+	var allow []bool
+	DB.WithContext(ctx).Model(&Share{}).Where("id = ?", id).Limit(1).Pluck("allow_embed", &allow)
+	if len(allow) == 1 {
+		return allow[0]
+	}
+	var n int64
+	DB.WithContext(ctx).Model(&Share{}).
+		Joins("JOIN share_refs ON share_refs.parent_share_id = shares.id").
+		Where("share_refs.snippet_id = ? AND shares.allow_embed = ? AND shares.expire_at > ?", id, true, time.Now()).
+		Limit(1).Count(&n)
+	return n > 0
+}
+
+// AddShareViews 批量累加浏览次数（counts 为分享或引用块片段 ID → 新增次数）
+func AddShareViews(ctx context.Context, counts map[string]int) error {
+	return DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for id, n := range counts {
+			res := tx.Model(&Share{}).Where("id = ?", id).
+				UpdateColumn("view_count", gorm.Expr("view_count + ?", n))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				continue
+			}
+			if err := tx.Model(&BlockSnippet{}).Where("id = ?", id).
+				UpdateColumn("view_count", gorm.Expr("view_count + ?", n)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
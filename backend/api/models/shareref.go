@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ShareRef 父分享与引用块片段的关联：父分享内容及其引用块中（嵌套）的块引用 → 该块的片段，发布时写入，查看时一次查询解析全部引用
+type ShareRef struct {
+	ParentShareID string    `gorm:"primaryKey;size:64" json:"parentShareId"`
+	BlockID       string    `gorm:"primaryKey;size:64" json:"blockId"`
+	SnippetID     string    `gorm:"size:64;index" json:"snippetId"`
+	Depth         int       `gorm:"default:1" json:"depth"` // 引用层数：1 为文档直接引用，2 为引用块中的引用，以此类推
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func (ShareRef) TableName() string { return "share_refs" }
+
+// ReplaceShareRefs 重建父分享的引用（含嵌套引用），删除因此不再被引用的片段。
+// 返回受影响的片段 ID（原引用与新引用，用于缓存失效）
+func ReplaceShareRefs(tx *gorm.DB, parentShareID string, refs []ShareRef) ([]string, error) {
+	old, err := linkedSnippetIDs(tx, []string{parentShareID})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("parent_share_id = ?", parentShareID).Delete(&ShareRef{}).Error; err != nil {
+		return nil, err
+	}
+	affected := old
+	if len(refs) > 0 {
+		for i := range refs {
+			refs[i].ParentShareID = parentShareID
+			affected = append(affected, refs[i].SnippetID)
+		}
+		if err := tx.CreateInBatches(refs, 200).Error; err != nil {
+			return nil, err
+		}
+	}
+	return affected, pruneSnippets(tx, old)
+}
+
+// ShareRefsOf 父分享的全部引用（含嵌套引用）
+func ShareRefsOf(ctx context.Context, parentShareID string) ([]ShareRef, error) {
+	var refs []ShareRef
+	err := DB.WithContext(ctx).Where("parent_share_id = ?", parentShareID).Order("depth, block_id").Find(&refs).Error
+	return refs, err
+}
+
+// ResolveBlockRefs 解析父分享中块引用对应的片段 ID（块 ID → 片段 ID），未发布的块不在结果中
+func ResolveBlockRefs(ctx context.Context, parent *Share, blockIDs []string) (map[string]string, error) {
+	resolved := make(map[string]string, len(blockIDs))
+	if len(blockIDs) == 0 {
+		return resolved, nil
+	}
+	const chunk = 500 // 控制 IN 列表长度，避免超出数据库的参数个数限制
+	for start := 0; start < len(blockIDs); start += chunk {
+		end := min(start+chunk, len(blockIDs))
+		var rows []ShareRef
+		if err := DB.WithContext(ctx).
+			Select("block_id", "snippet_id").
+			Where("parent_share_id = ? AND block_id IN ?", parent.ID, blockIDs[start:end]).
+			Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			resolved[r.BlockID] = r.SnippetID
+		}
+	}
+	return resolved, nil
+}
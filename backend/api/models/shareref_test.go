@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+)
+
+// BenchmarkResolveBlockRefs 解析父分享块引用的耗时随引用数量的变化：
+//
+//	indexed   发布时写入 share_refs，一次查询解析全部引用
+//	per-ref   旧实现：每个引用单独查询一次引用块，作对照
+//
+//	go test ./models -run '^$' -bench ResolveBlockRefs
+func BenchmarkResolveBlockRefs(b *testing.B) {
+	cfg := config.Default()
+	cfg.DataDir = b.TempDir()
+	cfg.Database.LogMode = "silent"
+	config.Set(cfg)
+	if err := InitDB(); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { Close() })
+	ctx := context.Background()
+
+	for _, n := range []int{1, 10, 100, 500} {
+		parent, blockIDs := seedShareRefs(b, fmt.Sprintf("u%d", n), n)
+		b.Run(fmt.Sprintf("refs=%d/indexed", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				resolved, err := ResolveBlockRefs(ctx, parent, blockIDs)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(resolved) != n {
+					b.Fatalf("resolved %d refs, want %d", len(resolved), n)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("refs=%d/per-ref", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, id := range blockIDs {
+					var snippet BlockSnippet
+					if err := DB.WithContext(ctx).Where("user_id = ? AND team_id = ? AND block_id = ?", parent.UserID, "", id).
+						First(&snippet).Error; err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// seedShareRefs 创建含 n 个块引用的父分享及其引用块片段，返回父分享与引用的块 ID
+func seedShareRefs(b *testing.B, userID string, n int) (*Share, []string) {
+	b.Helper()
+	parent := &Share{ID: userID + "-p", UserID: userID, DocID: "doc-" + userID, DocTitle: "bench",
+		ExpireAt: time.Now().Add(24 * time.Hour), IsPublic: true}
+	if err := DB.Create(parent).Error; err != nil {
+		b.Fatal(err)
+	}
+	blockIDs := make([]string, n)
+	rows := make([]ShareRef, n)
+	for i := 0; i < n; i++ {
+		blockIDs[i] = fmt.Sprintf("20240101%06d-%07d", i, i)
+		snippet := &BlockSnippet{ID: fmt.Sprintf("%s-b%d", userID, i), UserID: userID, BlockID: blockIDs[i], Content: fmt.Sprintf("block %d", i)}
+		if err := DB.Create(snippet).Error; err != nil {
+			b.Fatal(err)
+		}
+		rows[i] = ShareRef{BlockID: blockIDs[i], SnippetID: snippet.ID, Depth: 1}
+	}
+	if _, err := ReplaceShareRefs(DB, parent.ID, rows); err != nil {
+		b.Fatal(err)
+	}
+	return parent, blockIDs
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BlockSnippet 引用块片段：分享文档中 ((块ID)) 引用的块内容，公开地址为 /s/:id。
+// 每个所有者（个人或团队）的每个块只有一个片段，通过 share_refs 关联到引用它的全部父分享；
+// 片段本身没有密码与过期时间，访问条件由仍有效的父分享决定，最后一个父分享移除引用后片段随之删除
+type BlockSnippet struct {
+	ID string `gorm:"primaryKey;size:64" json:"id"`
+	// 个人片段 UserID 为所有者、TeamID 为空；团队片段 UserID 为空，与 OwnedBy 的查询范围一致
+	UserID  string `gorm:"size:64;uniqueIndex:idx_snippet_owner_block,priority:1" json:"userId"`
+	TeamID  string `gorm:"size:64;default:'';uniqueIndex:idx_snippet_owner_block,priority:2" json:"teamId"`
+	BlockID string `gorm:"size:64;uniqueIndex:idx_snippet_owner_block,priority:3" json:"blockId"`
+	Title   string `gorm:"size:255" json:"title"`
+	Content string `gorm:"type:text" json:"content"` // MySQL 上 content、references 为 LONGTEXT（迁移 11）
+	// References 片段内容引用的其他块（JSON []BlockReference，仅 blockId 与 displayText），查看时解析为片段链接
+	References string    `gorm:"type:text" json:"references"`
+	ViewCount  int       `gorm:"default:0" json:"viewCount"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func (BlockSnippet) TableName() string { return "block_snippets" }
+
+// SnippetOwner 父分享所有者对应的片段所有者：团队分享的片段归团队，不记录发布者
+func SnippetOwner(userID, teamID string) (string, string) {
+	if teamID != "" {
+		return "", teamID
+	}
+	return userID, ""
+}
+
+// FindSnippetsByBlocks 批量查找所有者的引用块片段（块 ID → 片段），在 db（可为事务）上查询
+func FindSnippetsByBlocks(db *gorm.DB, userID, teamID string, blockIDs []string) (map[string]*BlockSnippet, error) {
+	ownerUser, ownerTeam := SnippetOwner(userID, teamID)
+	found := make(map[string]*BlockSnippet, len(blockIDs))
+	const chunk = 500 // 控制 IN 列表长度，避免超出数据库的参数个数限制
+	for start := 0; start < len(blockIDs); start += chunk {
+		end := min(start+chunk, len(blockIDs))
+		var snippets []BlockSnippet
+		if err := db.Where("user_id = ? AND team_id = ? AND block_id IN ?", ownerUser, ownerTeam, blockIDs[start:end]).
+			Find(&snippets).Error; err != nil {
+			return nil, err
+		}
+		for i := range snippets {
+			found[snippets[i].BlockID] = &snippets[i]
+		}
+	}
+	return found, nil
+}
+
+// SnippetParents 引用片段的未删除父分享（片段 ID → 父分享，仅含展示与访问控制相关字段）
+func SnippetParents(ctx context.Context, snippetIDs ...string) (map[string][]Share, error) {
+	parents := make(map[string][]Share, len(snippetIDs))
+	if len(snippetIDs) == 0 {
+		return parents, nil
+	}
+	var rows []struct {
+		Share
+		SnippetID string
+	}
+	err := DB.WithContext(ctx).Table("shares").
+		Select("shares.id, shares.doc_id, shares.doc_title, shares.require_password, shares.password_hash, "+
+			"shares.expire_at, shares.allow_embed, shares.updated_at, share_refs.snippet_id").
+		Joins("JOIN share_refs ON share_refs.parent_share_id = shares.id").
+		Where("share_refs.snippet_id IN ? AND shares.deleted_at IS NULL", snippetIDs).
+		Order("shares.created_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		parents[r.SnippetID] = append(parents[r.SnippetID], r.Share)
+	}
+	return parents, nil
+}
+
+// SnippetsOfShare 父分享引用的全部片段，按块 ID 排序
+func SnippetsOfShare(ctx context.Context, parentShareID string) ([]BlockSnippet, error) {
+	var snippets []BlockSnippet
+	err := DB.WithContext(ctx).
+		Joins("JOIN share_refs ON share_refs.snippet_id = block_snippets.id").
+		Where("share_refs.parent_share_id = ?", parentShareID).
+		Order("block_snippets.block_id").
+		Find(&snippets).Error
+	return snippets, err
+}
+
+// ResolveSnippetRefs 解析片段中块引用对应的片段 ID（块 ID → 片段 ID），在片段所有者范围内查找
+func ResolveSnippetRefs(ctx context.Context, snippet *BlockSnippet, blockIDs []string) (map[string]string, error) {
+	resolved := make(map[string]string, len(blockIDs))
+	if len(blockIDs) == 0 {
+		return resolved, nil
+	}
+	found, err := FindSnippetsByBlocks(DB.WithContext(ctx), snippet.UserID, snippet.TeamID, blockIDs)
+	if err != nil {
+		return nil, err
+	}
+	for blockID, s := range found {
+		resolved[blockID] = s.ID
+	}
+	return resolved, nil
+}
+
+// EmbeddedSnippets 嵌入块对应的片段（块 ID → 片段）。只返回 parentShareIDs 中每个父分享都引用的块：
+// 嵌入内容随外层内容一起展示，其访问条件不能宽于外层内容
+func EmbeddedSnippets(ctx context.Context, parentShareIDs, blockIDs []string) (map[string]*BlockSnippet, error) {
+	found := make(map[string]*BlockSnippet, len(blockIDs))
+	if len(parentShareIDs) == 0 || len(blockIDs) == 0 {
+		return found, nil
+	}
+	db := DB.WithContext(ctx)
+	var ids []string
+	if err := db.Model(&ShareRef{}).
+		Where("parent_share_id IN ? AND block_id IN ?", parentShareIDs, blockIDs).
+		Group("snippet_id").
+		Having("COUNT(*) = ?", len(parentShareIDs)).
+		Pluck("snippet_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return found, nil
+	}
+	var snippets []BlockSnippet
+	if err := db.Where("id IN ?", ids).Find(&snippets).Error; err != nil {
+		return nil, err
+	}
+	for i := range snippets {
+		found[snippets[i].BlockID] = &snippets[i]
+	}
+	return found, nil
+}
+
+// SnippetBacklinks 直接引用片段的分享（share_refs 中第 1 层的父分享，未删除）与同一所有者的其他片段
+func SnippetBacklinks(ctx context.Context, snippet *BlockSnippet) ([]Share, []BlockSnippet, error) {
+	db := DB.WithContext(ctx)
+	var shares []Share
+	if err := db.Joins("JOIN share_refs ON share_refs.parent_share_id = shares.id").
+		Where("share_refs.snippet_id = ? AND share_refs.depth = ?", snippet.ID, 1).
+		Order("shares.created_at").
+		Find(&shares).Error; err != nil {
+		return nil, nil, err
+	}
+	// references 为 json.Marshal 生成的紧凑 JSON，按 "blockId":"..." 匹配
+	var snippets []BlockSnippet
+	if err := db.Where("user_id = ? AND team_id = ? AND id <> ?", snippet.UserID, snippet.TeamID, snippet.ID).
+		Where(db.Statement.Quote("references")+" LIKE ?", `%"blockId":"`+snippet.BlockID+`"%`).
+		Order("created_at").
+		Find(&snippets).Error; err != nil {
+		return nil, nil, err
+	}
+	return shares, snippets, nil
+}
+
+// linkedSnippetIDs 父分享当前引用的片段 ID
+func linkedSnippetIDs(tx *gorm.DB, parentShareIDs []string) ([]string, error) {
+	var ids []string
+	err := tx.Model(&ShareRef{}).Distinct("snippet_id").
+		Where("parent_share_id IN ?", parentShareIDs).
+		Pluck("snippet_id", &ids).Error
+	return ids, err
+}
+
+// pruneSnippets 删除 ids 中已没有任何父分享引用的片段
+func pruneSnippets(tx *gorm.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Where("id IN ?", ids).
+		Where("NOT EXISTS (SELECT 1 FROM share_refs WHERE share_refs.snippet_id = block_snippets.id)").
+		Delete(&BlockSnippet{}).Error
+}
+
+// DetachShares 移除父分享的全部引用并删除不再被引用的片段，返回受影响的片段 ID（用于缓存失效）。
+// 仍被其他父分享引用的片段保留，访问条件改由剩余父分享决定
+func DetachShares(tx *gorm.DB, parentShareIDs ...string) ([]string, error) {
+	if len(parentShareIDs) == 0 {
+		return nil, nil
+	}
+	ids, err := linkedSnippetIDs(tx, parentShareIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("parent_share_id IN ?", parentShareIDs).Delete(&ShareRef{}).Error; err != nil {
+		return nil, err
+	}
+	return ids, pruneSnippets(tx, ids)
+}
+
+// MoveShareSnippets 父分享转移所有者后，将其引用改为指向新所有者的片段（不存在时复制创建），
+// 原片段仍归原所有者，不再被引用时删除。返回受影响的片段 ID
+func MoveShareSnippets(tx *gorm.DB, parent *Share, newID func() string) ([]string, error) {
+	var refs []ShareRef
+	if err := tx.Where("parent_share_id = ?", parent.ID).Find(&refs).Error; err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	oldIDs := make([]string, 0, len(refs))
+	for _, r := range refs {
+		oldIDs = append(oldIDs, r.SnippetID)
+	}
+	var old []BlockSnippet
+	if err := tx.Where("id IN ?", oldIDs).Find(&old).Error; err != nil {
+		return nil, err
+	}
+	ownerUser, ownerTeam := SnippetOwner(parent.UserID, parent.TeamID)
+	blockIDs := make([]string, 0, len(old))
+	for _, s := range old {
+		blockIDs = append(blockIDs, s.BlockID)
+	}
+	target, err := FindSnippetsByBlocks(tx, parent.UserID, parent.TeamID, blockIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	affected := append([]string{}, oldIDs...)
+	for _, s := range old {
+		if s.UserID == ownerUser && s.TeamID == ownerTeam {
+			continue
+		}
+		dst := target[s.BlockID]
+		if dst == nil {
+			dst = &BlockSnippet{ID: newID(), UserID: ownerUser, TeamID: ownerTeam, BlockID: s.BlockID, Title: s.Title, Content: s.Content, References: s.References}
+			if err := tx.Create(dst).Error; err != nil {
+				return nil, err
+			}
+			target[s.BlockID] = dst
+		}
+		if err := tx.Model(&ShareRef{}).
+			Where("parent_share_id = ? AND snippet_id = ?", parent.ID, s.ID).
+			Update("snippet_id", dst.ID).Error; err != nil {
+			return nil, err
+		}
+		affected = append(affected, dst.ID)
+	}
+	return affected, pruneSnippets(tx, oldIDs)
+}
+
+// DeleteTeamSnippets 删除团队的全部片段及指向它们的残留引用，返回被删除的片段 ID（用于缓存失效）。
+// 仅在团队已没有分享时调用：此时片段不再有有效的父分享，无法再被访问
+func DeleteTeamSnippets(tx *gorm.DB, teamID string) ([]string, error) {
+	var ids []string
+	if err := tx.Model(&BlockSnippet{}).Where("team_id = ?", teamID).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := tx.Where("snippet_id IN ?", ids).Delete(&ShareRef{}).Error; err != nil {
+		return nil, err
+	}
+	return ids, tx.Where("id IN ?", ids).Delete(&BlockSnippet{}).Error
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 团队成员角色
+const (
+	TeamRoleOwner  = "owner"  // 管理团队与成员，可转移团队分享
+	TeamRoleEditor = "editor" // 可创建、更新、删除团队分享
+	TeamRoleViewer = "viewer" // 仅可查看团队分享列表
+)
+
+// Team 团队（工作区），分享可归属于团队以实现共享所有权
+type Team struct {
+	ID        string         `gorm:"primaryKey;size:64" json:"id"`
+	Name      string         `gorm:"size:100" json:"name"`
+	CreatedBy string         `gorm:"size:64" json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Team) TableName() string { return "teams" }
+
+// TeamMember 团队成员关系
+type TeamMember struct {
+	TeamID    string    `gorm:"primaryKey;size:64" json:"teamId"`
+	UserID    string    `gorm:"primaryKey;size:64;index" json:"userId"`
+	Role      string    `gorm:"size:20" json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (TeamMember) TableName() string { return "team_members" }
+
+// ValidTeamRole 检查角色是否合法
+func ValidTeamRole(role string) bool {
+	return role == TeamRoleOwner || role == TeamRoleEditor || role == TeamRoleViewer
+}
+
+// GetTeamRole 返回用户在团队中的角色，非成员返回空字符串
+func GetTeamRole(teamID, userID string) (string, error) {
+	if teamID == "" || userID == "" {
+		return "", nil
+	}
+	var m TeamMember
+	err := DB.Joins("JOIN teams ON teams.id = team_members.team_id AND teams.deleted_at IS NULL").
+		Where("team_members.team_id = ? AND team_members.user_id = ?", teamID, userID).
+		First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return m.Role, nil
+}
+
+// CanEditTeamShares 角色是否可以增删改团队分享
+func CanEditTeamShares(role string) bool {
+	return role == TeamRoleOwner || role == TeamRoleEditor
+}
+
+// CountTeamOwners 统计团队所有者数量（保证团队至少保留一名所有者）
+func CountTeamOwners(tx *gorm.DB, teamID string) (int64, error) {
+	var n int64
+	err := tx.Model(&TeamMember{}).Where("team_id = ? AND role = ?", teamID, TeamRoleOwner).Count(&n).Error
+	return n, err
+}
@@ -8,15 +8,22 @@ import (
 
 // User 用户模型
 type User struct {
-	ID           string         `gorm:"primaryKey;size:64" json:"id"`
-	Username     string         `gorm:"size:100;uniqueIndex" json:"username"`
-	Email        string         `gorm:"size:255;uniqueIndex" json:"email"`
-	PasswordHash string         `gorm:"size:255" json:"-"` // 密码哈希
-	IsActive     bool           `gorm:"default:true" json:"isActive"`
-	CreatedAt    time.Time      `json:"createdAt"`
-	UpdatedAt    time.Time      `json:"updatedAt"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
-	Tokens       []UserToken    `json:"-"` // 关联的多 API Token
+	ID              string         `gorm:"primaryKey;size:64" json:"id"`
+	Username        string         `gorm:"size:100;uniqueIndex" json:"username"`
+	Email           string         `gorm:"size:255;uniqueIndex" json:"email"`
+	PasswordHash    string         `gorm:"size:255" json:"-"` // 密码哈希
+	IsActive        bool           `gorm:"default:true" json:"isActive"`
+	IsAdmin         bool           `gorm:"default:false" json:"isAdmin"`
+	TOTPSecret      string         `gorm:"size:64" json:"-"` // TOTP 密钥，启用前即写入，校验通过后才置 TOTPEnabled
+	TOTPEnabled     bool           `gorm:"default:false" json:"totpEnabled"`
+	TOTPLastCounter int64          `gorm:"default:0" json:"-"` // 最近一次通过校验的时间步，防止验证码重放
+	TOTPFailures    int            `gorm:"default:0" json:"-"` // 连续校验失败次数，成功或锁定后清零
+	TOTPLockedAt    *time.Time     `json:"-"`                  // 最近一次因连续失败被锁定的时间，此前签发的挑战令牌作废
+	SessionVersion  int            `gorm:"default:0" json:"-"` // 会话版本，写入会话 JWT；启用或重置 2FA 时递增，已签发的会话随之失效
+	CreatedAt       time.Time      `json:"createdAt"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	Tokens          []UserToken    `json:"-"` // 关联的多 API Token
 }
 
 // TableName 指定表名
@@ -39,3 +46,14 @@ type UserToken struct {
 }
 
 func (UserToken) TableName() string { return "user_tokens" }
+
+// RecoveryCode 双因素认证的一次性恢复码（仅存哈希）
+type RecoveryCode struct {
+	ID        string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID    string     `gorm:"index;size:64" json:"userId"`
+	CodeHash  string     `gorm:"size:255;index" json:"-"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (RecoveryCode) TableName() string { return "recovery_codes" }
@@ -0,0 +1,301 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "siyuan-share"
+	testKid      = "test-key-1"
+	testNonce    = "nonce-123"
+)
+
+// testIdP 与 tools/mockidp 行为一致的测试身份提供方：发现文档、JWKS 与校验 PKCE 的令牌端点
+type testIdP struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string // 授权请求中的 code_challenge
+	nonce     string // 授权请求中的 nonce
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                           idp.URL,
+			"authorization_endpoint":           idp.URL + "/authorize",
+			"token_endpoint":                   idp.URL + "/token",
+			"jwks_uri":                         idp.URL + "/jwks",
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": testKid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("code") != "auth-code" {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		if CodeChallenge(r.PostForm.Get("code_verifier")) != idp.challenge {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idp.sign(t, jwt.SigningMethodRS256, idp.claims(idp.nonce)),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// claims 有效的 ID Token 声明
+func (idp *testIdP) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+	}
+}
+
+func (idp *testIdP) sign(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = testKid
+	s, err := tok.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (idp *testIdP) discover(t *testing.T) *Provider {
+	t.Helper()
+	p, err := Discover(context.Background(), Config{Issuer: idp.URL, ClientID: testClientID, RedirectURL: "http://app/callback"}, idp.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.discover(t)
+	with := func(mutate func(jwt.MapClaims)) jwt.MapClaims {
+		c := idp.claims(testNonce)
+		mutate(c)
+		return c
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signWith := func(method jwt.SigningMethod, key interface{}, kid string) string {
+		tok := jwt.NewWithClaims(method, idp.claims(testNonce))
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		nonce   string
+		wantErr bool
+	}{
+		{"valid", idp.sign(t, jwt.SigningMethodRS256, idp.claims(testNonce)), testNonce, false},
+		{"valid RS512", idp.sign(t, jwt.SigningMethodRS512, idp.claims(testNonce)), testNonce, false},
+		{"multiple audiences with azp", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) {
+			c["aud"] = []string{testClientID, "other"}
+			c["azp"] = testClientID
+		})), testNonce, false},
+		{"iat within leeway", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) {
+			c["iat"] = time.Now().Add(30 * time.Second).Unix()
+		})), testNonce, false},
+
+		{"wrong issuer", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" })), testNonce, true},
+		{"missing issuer", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "iss") })), testNonce, true},
+		{"wrong audience", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["aud"] = "other-client" })), testNonce, true},
+		{"multiple audiences without azp", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) {
+			c["aud"] = []string{testClientID, "other"}
+		})), testNonce, true},
+		{"multiple audiences with foreign azp", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) {
+			c["aud"] = []string{testClientID, "other"}
+			c["azp"] = "other"
+		})), testNonce, true},
+		{"expired beyond leeway", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-2 * time.Minute).Unix()
+		})), testNonce, true},
+		{"missing exp", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "exp") })), testNonce, true},
+		{"issued in the future", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) {
+			c["iat"] = time.Now().Add(10 * time.Minute).Unix()
+		})), testNonce, true},
+		{"wrong nonce", idp.sign(t, jwt.SigningMethodRS256, idp.claims("other-nonce")), testNonce, true},
+		{"missing nonce claim", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "nonce") })), testNonce, true},
+		{"empty expected nonce", idp.sign(t, jwt.SigningMethodRS256, idp.claims("")), "", true},
+		{"missing subject", idp.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "sub") })), testNonce, true},
+
+		{"alg none", signWith(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, testKid), testNonce, true},
+		{"alg HS256 with public key as secret", signWith(jwt.SigningMethodHS256, idp.key.N.Bytes(), testKid), testNonce, true},
+		{"ES256 with unknown key", signWith(jwt.SigningMethodES256, ecKey, testKid), testNonce, true},
+		{"signed by another key", signWith(jwt.SigningMethodRS256, otherKey, testKid), testNonce, true},
+		{"unknown kid", signWith(jwt.SigningMethodRS256, idp.key, "rotated-away"), testNonce, true},
+		{"malformed", "not.a.jwt", testNonce, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := p.VerifyIDToken(context.Background(), tt.token, tt.nonce)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("VerifyIDToken() accepted the token: %+v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIDToken() = %v", err)
+			}
+			if claims.Subject != "user-1" || claims.Email != "alice@example.com" || claims.EmailVerified == nil || !*claims.EmailVerified {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenEmailVerified(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.discover(t)
+	tests := []struct {
+		name  string
+		value interface{} // nil 表示不提供该声明
+		want  string
+	}{
+		{"true", true, "true"},
+		{"false", false, "false"},
+		{"string true", "true", "true"},
+		{"string false", "false", "false"},
+		{"absent", nil, "nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := idp.claims(testNonce)
+			delete(c, "email_verified")
+			if tt.value != nil {
+				c["email_verified"] = tt.value
+			}
+			claims, err := p.VerifyIDToken(context.Background(), idp.sign(t, jwt.SigningMethodRS256, c), testNonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := "nil"
+			if claims.EmailVerified != nil {
+				got = map[bool]string{true: "true", false: "false"}[*claims.EmailVerified]
+			}
+			if got != tt.want {
+				t.Errorf("EmailVerified = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAuthCodeFlow 发现 → 授权地址 → 令牌交换（PKCE）→ ID Token 校验的完整流程
+func TestAuthCodeFlow(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.discover(t)
+
+	state, nonce, verifier := RandomString(16), RandomString(16), RandomString(32)
+	u, err := url.Parse(p.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "http://app/callback",
+		"scope":                 "openid email profile",
+		"state":                 state,
+		"nonce":                 nonce,
+		"code_challenge":        CodeChallenge(verifier),
+		"code_challenge_method": "S256",
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("authorize %s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+	if !strings.HasPrefix(u.String(), idp.URL+"/authorize?") {
+		t.Errorf("authorize URL = %s", u)
+	}
+
+	// 身份提供方记录授权请求后回调授权码
+	idp.challenge, idp.nonce = q.Get("code_challenge"), q.Get("nonce")
+
+	if _, err := p.Exchange(context.Background(), "auth-code", "wrong-verifier"); err == nil {
+		t.Error("Exchange accepted a wrong PKCE verifier")
+	}
+	tr, err := p.Exchange(context.Background(), "auth-code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.VerifyIDToken(context.Background(), tr.IDToken, RandomString(16)); err == nil {
+		t.Error("VerifyIDToken accepted the token with another login's nonce")
+	}
+	claims, err := p.VerifyIDToken(context.Background(), tr.IDToken, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-1" {
+		t.Errorf("Subject = %q, want user-1", claims.Subject)
+	}
+}
@@ -0,0 +1,118 @@
+// Package proxy 判定请求是否来自受信任的反向代理，并据此解析客户端 IP、协议与主机名。
+// 只有来自 server.trustedProxies 中网段或 Unix 套接字的请求，其 X-Forwarded-* 头才会被采信。
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type unixConnKey struct{}
+
+var (
+	mu      sync.RWMutex
+	trusted []*net.IPNet
+)
+
+// SetTrusted 设置受信任的代理网段（启动时调用一次）
+func SetTrusted(nets []*net.IPNet) {
+	mu.Lock()
+	trusted = nets
+	mu.Unlock()
+}
+
+// MarkUnix 用作 http.Server.ConnContext：Unix 套接字上的连接只可能来自本机反向代理，视为受信任
+func MarkUnix(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, unixConnKey{}, true)
+}
+
+func viaUnix(r *http.Request) bool {
+	v, _ := r.Context().Value(unixConnKey{}).(bool)
+	return v
+}
+
+func isTrustedIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// Trusted 请求的直接对端是否为受信任代理
+func Trusted(r *http.Request) bool {
+	return viaUnix(r) || isTrustedIP(remoteIP(r))
+}
+
+// ClientIP 客户端 IP：对端为受信任代理时从右向左跳过 X-Forwarded-For 中的受信任代理，
+// 取第一个不受信任的地址；否则为连接对端地址。经 Unix 套接字且无转发头时返回空串
+func ClientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !Trusted(r) {
+		if ip == nil {
+			return ""
+		}
+		return ip.String()
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !isTrustedIP(hop) {
+			break
+		}
+	}
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// Scheme 请求协议（http / https）：TLS 直连为 https，受信任代理可通过 X-Forwarded-Proto 声明
+func Scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if Trusted(r) {
+		if p := strings.ToLower(firstValue(r.Header.Get("X-Forwarded-Proto"))); p == "https" || p == "http" {
+			return p
+		}
+	}
+	return "http"
+}
+
+// Host 请求主机名（含端口）：受信任代理可通过 X-Forwarded-Host 声明
+func Host(r *http.Request) string {
+	if Trusted(r) {
+		if h := firstValue(r.Header.Get("X-Forwarded-Host")); h != "" && !strings.ContainsAny(h, "/\\@ ") {
+			return h
+		}
+	}
+	return r.Host
+}
+
+// firstValue 多级代理时取逗号分隔列表的第一项（最外层代理收到的值）
+func firstValue(v string) string {
+	v, _, _ = strings.Cut(v, ",")
+	return strings.TrimSpace(v)
+}
@@ -1,18 +1,24 @@
 package routes
 
 import (
+	"bytes"
 	"embed"
+	"html/template"
 	"io/fs"
+	"log/slog"
 	"mime"
 	"net/http"
-	"os"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
 	"github.com/ZeroHawkeye/siyuan-share-api/controllers"
+	"github.com/ZeroHawkeye/siyuan-share-api/metrics"
 	"github.com/ZeroHawkeye/siyuan-share-api/middleware"
 	"github.com/ZeroHawkeye/siyuan-share-api/models"
+	"github.com/ZeroHawkeye/siyuan-share-api/proxy"
 	gz "github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 )
@@ -21,30 +27,74 @@ import (
 func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 	// 自定义 Engine 以便关闭不必要的中间件或切换 JSON 序列化库
 	r := gin.New()
-	r.Use(gin.Recovery())
-	// 仅在开发时启用默认 Logger，生产可通过 GIN_MODE=release 禁用
-	if gin.Mode() != gin.ReleaseMode {
-		r.Use(gin.Logger())
+	// 请求 ID 最先分配，后续的 panic 日志、访问日志与 SQL 日志均可关联
+	r.Use(middleware.RequestIDMiddleware(), middleware.RecoveryMiddleware())
+	// 结构化访问日志（生产环境同样启用，可通过 ACCESS_LOG=false 关闭）
+	if config.Get().Log.AccessLog {
+		r.Use(middleware.AccessLogMiddleware())
+	}
+
+	// 仅采信受信任代理的 X-Forwarded-*（gin 默认信任所有来源）
+	trusted, _ := config.ParseIPNets(config.Get().Server.TrustedProxies) // 启动时已校验
+	proxy.SetTrusted(trusted)
+	if err := r.SetTrustedProxies(config.Get().Server.TrustedProxies); err != nil {
+		slog.Warn("Invalid trusted proxies", "error", err)
 	}
 
 	// 禁用自动重定向，避免根路径触发 301
 	r.RedirectTrailingSlash = false
 	r.RedirectFixedPath = false
 
-	// 使用 CORS 中间件 & 响应压缩
-	r.Use(middleware.CORSMiddleware())
+	// 挂载前缀（如 /notes）：API 位于 {basePath}/api，前端位于 {basePath}/
+	basePath := config.Get().Server.BasePath
+
+	// 安全响应头、CORS 来源白名单 & 响应压缩
+	r.Use(middleware.SecurityHeadersMiddleware(config.Get().Security, basePath))
+	r.Use(middleware.CORSMiddleware(config.Get().CORS, basePath))
 	r.Use(gz.Gzip(gz.BestSpeed))
+
+	// Prometheus 指标：请求计数与耗时中间件 + 受保护的抓取端点（{basePath}{metrics.path}），
+	// 注册在全局中间件之后，抓取端点同样带安全响应头与 CORS 校验
+	if cfg := config.Get().Metrics; cfg.Enabled {
+		r.Use(middleware.MetricsMiddleware())
+		metrics.SetShareStatsFunc(func() (metrics.ShareStats, error) {
+			active, expired, err := models.CountShareStats()
+			return metrics.ShareStats{Active: active, PendingCleanup: expired}, err
+		})
+		r.GET(basePath+cfg.Path, middleware.MetricsAuthMiddleware(cfg), gin.WrapH(metrics.Handler()))
+	}
+
 	// 静态文件服务（前端）
 	if staticFiles != nil {
 		// 获取嵌入的 dist 子文件系统
 		distFS, err := fs.Sub(*staticFiles, "dist")
 		if err == nil {
+			// index.html 的资源路径按浏览器可见的路径前缀改写
+			indexHTML, _ := fs.ReadFile(distFS, "index.html")
+			indexHTML = rewriteIndexHTML(indexHTML, config.Get().Server.PublicPath())
+
 			// 处理静态文件和 SPA 路由
 			r.NoRoute(func(c *gin.Context) {
 				requestPath := c.Request.URL.Path
 
+				// 挂载前缀之外的路径：根路径跳转到前缀，其余返回 404
+				if basePath != "" {
+					switch {
+					case requestPath == "/":
+						c.Redirect(http.StatusFound, basePath+"/")
+						return
+					case requestPath == basePath:
+						requestPath = "/"
+					case strings.HasPrefix(requestPath, basePath+"/"):
+						requestPath = strings.TrimPrefix(requestPath, basePath)
+					default:
+						c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "not found"})
+						return
+					}
+				}
+
 				// API 路由返回 404
-				if strings.HasPrefix(requestPath, "/api") {
+				if requestPath == "/api" || strings.HasPrefix(requestPath, "/api/") {
 					c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "not found"})
 					return
 				}
@@ -71,6 +121,9 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 					if err != nil {
 						return false
 					}
+					if target == "index.html" {
+						data = indexHTML
+					}
 
 					ext := strings.ToLower(path.Ext(target))
 					contentType := mime.TypeByExtension(ext)
@@ -105,25 +158,27 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 		}
 	}
 	// API 路由组 - 所有后端 API 都在 /api 前缀下
-	api := r.Group("/api")
+	api := r.Group(basePath + "/api")
 	{
-		// 健康检查（公开）
-		api.GET("/health", func(c *gin.Context) {
-			var userCount int64
-			models.DB.Model(&models.User{}).Count(&userCount)
-			c.JSON(http.StatusOK, gin.H{
-				"status":    "ok",
-				"ts":        time.Now().Unix(),
-				"userCount": userCount,
-				"ginMode":   os.Getenv("GIN_MODE"),
-				"version":   "v1", // 可后续从构建信息注入
-			})
-		})
+		// 健康检查（公开）：/health 与 /health/live 为存活探针，/health/ready 为就绪探针（检查数据库）
+		api.GET("/health", controllers.Liveness)
+		api.GET("/health/live", controllers.Liveness)
+		api.GET("/health/ready", controllers.Readiness)
 
 		// 注册与登录（无需认证）
 		api.POST("/auth/register", controllers.Register)
 		api.POST("/auth/login", controllers.Login)
 
+		// 双因素认证登录第二步（凭挑战令牌，无需会话）
+		api.POST("/auth/2fa/verify", controllers.TwoFactorVerify)
+		api.POST("/auth/2fa/enroll/setup", controllers.TwoFactorEnrollSetup)
+		api.POST("/auth/2fa/enroll/confirm", controllers.TwoFactorEnrollConfirm)
+
+		// OIDC 单点登录
+		api.GET("/auth/oidc/config", controllers.OIDCConfig)
+		api.GET("/auth/oidc/login", controllers.OIDCLogin)
+		api.GET("/auth/oidc/callback", controllers.OIDCCallback)
+
 		// 健康检查（需要认证，用于测试 API Token）
 		api.GET("/auth/health", middleware.AuthMiddleware(), func(c *gin.Context) {
 			userID, _ := c.Get("userID")
@@ -146,12 +201,54 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 			share.GET("/list", controllers.ListShares)
 			share.DELETE("/batch", controllers.DeleteSharesBatch)
 			share.DELETE(":id", controllers.DeleteShare)
+			share.POST("/:id/transfer", controllers.TransferShare)
+			share.GET("/:id/tree", controllers.GetShareTree)
+		}
+
+		// 团队（工作区）管理
+		team := api.Group("/team")
+		team.Use(middleware.AuthMiddleware())
+		{
+			team.GET("/list", controllers.ListTeams)
+			team.POST("/create", controllers.CreateTeam)
+			team.GET("/:id", controllers.GetTeam)
+			team.PUT("/:id", controllers.UpdateTeam)
+			team.DELETE("/:id", controllers.DeleteTeam)
+			team.POST("/:id/members", controllers.AddTeamMember)
+			team.PUT("/:id/members/:userId", controllers.UpdateTeamMember)
+			team.DELETE("/:id/members/:userId", controllers.RemoveTeamMember)
 		}
 
 		user := api.Group("/user")
 		user.Use(middleware.AuthMiddleware())
 		{
 			user.GET("/me", controllers.Me)
+			user.GET("/scan-settings", controllers.GetScanSettings)
+			user.PUT("/scan-settings", controllers.UpdateScanSettings)
+			user.GET("/pipeline", controllers.GetPipelineSettings)
+			user.PUT("/pipeline", controllers.UpdatePipelineSettings)
+		}
+
+		// 双因素认证管理（仅限 Web 会话，API Token 不可操作）
+		twoFactor := api.Group("/user/2fa")
+		twoFactor.Use(middleware.AuthMiddleware(), middleware.SessionOnlyMiddleware())
+		{
+			twoFactor.POST("/setup", controllers.SetupTOTP)
+			twoFactor.POST("/enable", controllers.EnableTOTP)
+			twoFactor.POST("/disable", controllers.DisableTOTP)
+			twoFactor.POST("/recovery-codes", controllers.RegenerateRecoveryCodes)
+		}
+
+		// 管理员接口
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			admin.GET("/settings/security", controllers.GetSecuritySettings)
+			admin.PUT("/settings/security", controllers.UpdateSecuritySettings)
+			admin.POST("/users/:id/2fa/reset", controllers.ResetUserTOTP)
+			admin.GET("/backups", controllers.ListBackups)
+			admin.POST("/backups", controllers.CreateBackup)
+			admin.POST("/backups/:name/verify", controllers.VerifyBackup)
 		}
 
 		// Token 管理端点（需要认证）
@@ -166,7 +263,21 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 
 		// 公开访问的分享查看接口
 		api.GET("/s/:id", controllers.GetShare)
+		api.GET("/s/:id/backlinks", controllers.GetShareBacklinks)
 	}
 
 	return r
 }
+
+// assetRef index.html 中以 / 或 ./ 开头的站内资源引用（不含 // 开头的协议相对地址）
+var assetRef = regexp.MustCompile(`(\s(?:src|href)=["'])\.?/([^/"'])`)
+
+// rewriteIndexHTML 为资源引用加上路径前缀，并通过 <meta name="base-path"> 告知前端路由与 API 的前缀
+func rewriteIndexHTML(html []byte, prefix string) []byte {
+	out := assetRef.ReplaceAll(html, []byte("${1}"+prefix+"/${2}"))
+	meta := `<meta name="base-path" content="` + template.HTMLEscapeString(prefix) + `">`
+	if i := bytes.Index(out, []byte("</head>")); i >= 0 {
+		return append(out[:i:i], append([]byte(meta), out[i:]...)...)
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"regexp"
+	"strings"
+)
+
+// builtinRules 内置规则：云服务密钥、私钥、JWT、邮箱、手机号与证件号
+var builtinRules = []Rule{
+	{ID: "aws-access-key", Name: "AWS Access Key ID", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{ID: "aws-secret-key", Name: "AWS Secret Access Key", Group: 1,
+		Pattern: regexp.MustCompile(`(?i)aws.{0,20}?secret.{0,20}?[=:"'\s]([A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+]|$)`)},
+	{ID: "gcp-api-key", Name: "Google API Key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{ID: "aliyun-access-key", Name: "Alibaba Cloud AccessKey ID", Pattern: regexp.MustCompile(`\bLTAI[0-9A-Za-z]{12,20}\b`)},
+	{ID: "tencent-secret-id", Name: "Tencent Cloud SecretId", Pattern: regexp.MustCompile(`\bAKID[0-9A-Za-z]{32}\b`)},
+	{ID: "github-token", Name: "GitHub Token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[0-9A-Za-z]{36,}|github_pat_[0-9A-Za-z_]{22,})\b`)},
+	{ID: "slack-token", Name: "Slack Token", Pattern: regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}\b`)},
+	{ID: "api-secret-key", Name: "API Secret Key (sk-)", Pattern: regexp.MustCompile(`\bsk-(?:proj-|ant-)?[A-Za-z0-9_-]{20,}\b`)},
+	// private-key 匹配整个 PEM 块（含密钥正文），缺少 END 行时匹配到文本末尾，避免打码后仍发布密钥正文
+	{ID: "private-key", Name: "Private Key", Pattern: regexp.MustCompile(
+		`(?s)-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----.*?(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----|\z)`)},
+	{ID: "jwt", Name: "JSON Web Token", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{ID: "email", Name: "Email Address", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`)},
+	{ID: "phone-cn", Name: "Mobile Phone Number (CN)", Group: 1,
+		Pattern: regexp.MustCompile(`(?:^|[^0-9])((?:\+?86[ -]?)?1[3-9][0-9](?:[ -]?[0-9]{4}){2})(?:[^0-9]|$)`)},
+	{ID: "phone-intl", Name: "Phone Number (international)", Group: 1,
+		Pattern: regexp.MustCompile(`(?:^|[^0-9+])(\+[1-9][0-9]{0,2}[ -]?\(?[0-9]{1,4}\)?(?:[ -]?[0-9]{2,4}){2,4})(?:[^0-9]|$)`)},
+	{ID: "cn-id-card", Name: "Resident ID Number (CN)", Group: 1, Validate: validCNIDCard,
+		Pattern: regexp.MustCompile(`(?:^|[^0-9])([1-9][0-9]{5}(?:18|19|20)[0-9]{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12][0-9]|3[01])[0-9]{3}[0-9Xx])(?:[^0-9Xx]|$)`)},
+	{ID: "us-ssn", Name: "Social Security Number (US)", Pattern: regexp.MustCompile(`\b[0-8][0-9]{2}-[0-9]{2}-[0-9]{4}\b`)},
+	{ID: "credit-card", Name: "Payment Card Number", Group: 1, Validate: validCardNumber,
+		Pattern: regexp.MustCompile(`(?:^|[^0-9])((?:[0-9][ -]?){12,18}[0-9])(?:[^0-9]|$)`)},
+}
+
+// BuiltinRules 内置规则（只读）
+func BuiltinRules() []Rule {
+	return append([]Rule(nil), builtinRules...)
+}
+
+// validCNIDCard 18 位居民身份证号的校验码（GB 11643）
+func validCNIDCard(s string) bool {
+	weights := [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	const checks = "10X98765432"
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(s[i]-'0') * weights[i]
+	}
+	last := s[17]
+	if last == 'x' {
+		last = 'X'
+	}
+	return checks[sum%11] == last
+}
+
+// cardPrefix 常见卡组织的卡号前缀（Visa、Mastercard、American Express、银联、Discover、JCB），
+// 排除思源块 ID 中的时间戳等长数字
+var cardPrefix = regexp.MustCompile(`^(?:4|5[1-5]|2[2-7]|3[47]|62|65|6011|35)`)
+
+// validCardNumber 卡号前缀与 Luhn 校验（忽略空格与连字符）
+func validCardNumber(s string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if !cardPrefix.MatchString(digits) {
+		return false
+	}
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
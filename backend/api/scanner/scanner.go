@@ -0,0 +1,147 @@
+// Package scanner 在发布前检测内容中的密钥与个人信息：内置规则加用户自定义规则，白名单中的匹配不报告。
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rule 检测规则
+type Rule struct {
+	ID       string
+	Name     string
+	Pattern  *regexp.Regexp
+	Group    int               // 敏感内容所在的捕获组，0 为整个匹配
+	Validate func(string) bool // 可选：进一步校验匹配内容（如校验码），返回 false 时忽略
+}
+
+// Finding 检测结果。位置按来源文本计算，行、列从 1 开始，列按字符计
+type Finding struct {
+	Rule   string `json:"rule"`
+	Name   string `json:"name"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Length int    `json:"length"` // 匹配内容的字符数
+	Masked string `json:"masked"` // 打码后的匹配内容，不返回原文
+
+	start, end int // 字节偏移，用于打码替换
+}
+
+// Scanner 检测器
+type Scanner struct {
+	rules []Rule
+	allow []*regexp.Regexp
+}
+
+// New 创建检测器：rules 为生效的规则（内置与自定义），allow 中任一正则匹配的内容不报告
+func New(rules []Rule, allow []*regexp.Regexp) *Scanner {
+	return &Scanner{rules: rules, allow: allow}
+}
+
+// CustomRule 由用户正则创建规则，ID 为 custom:<name>
+func CustomRule(name, pattern string) (Rule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %w", name, err)
+	}
+	return Rule{ID: "custom:" + name, Name: name, Pattern: re}, nil
+}
+
+// Scan 检测文本，结果按位置排序，重叠的匹配只保留先出现的一个
+func (s *Scanner) Scan(text string) []Finding {
+	var found []Finding
+	for _, r := range s.rules {
+		for _, loc := range r.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			g := r.Group
+			if 2*g+1 >= len(loc) || loc[2*g] < 0 {
+				g = 0
+			}
+			start, end := loc[2*g], loc[2*g+1]
+			if start == end {
+				continue
+			}
+			match := text[start:end]
+			if (r.Validate != nil && !r.Validate(match)) || s.allowed(match) {
+				continue
+			}
+			found = append(found, Finding{Rule: r.ID, Name: r.Name, Masked: mask(match), start: start, end: end})
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].start != found[j].start {
+			return found[i].start < found[j].start
+		}
+		return found[i].end > found[j].end
+	})
+	kept := found[:0]
+	for _, f := range found {
+		if len(kept) > 0 && f.start < kept[len(kept)-1].end {
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	// 计算行列：逐段推进，避免每个结果从头扫描
+	line, lineStart, pos := 1, 0, 0
+	for i := range kept {
+		f := &kept[i]
+		for pos < f.start {
+			if text[pos] == '\n' {
+				line++
+				lineStart = pos + 1
+			}
+			pos++
+		}
+		f.Line = line
+		f.Column = utf8.RuneCountInString(text[lineStart:f.start]) + 1
+		f.Length = utf8.RuneCountInString(text[f.start:f.end])
+	}
+	return kept
+}
+
+func (s *Scanner) allowed(match string) bool {
+	for _, re := range s.allow {
+		if re.MatchString(match) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact 将检测结果替换为占位符 [REDACTED:<rule>]，findings 须来自对同一文本的 Scan
+func Redact(text string, findings []Finding) string {
+	if len(findings) == 0 {
+		return text
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	last := 0
+	for _, f := range findings {
+		b.WriteString(text[last:f.start])
+		b.WriteString("[REDACTED:" + f.Rule + "]")
+		last = f.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// mask 保留首尾少量字符，其余替换为 *
+func mask(s string) string {
+	r := []rune(s)
+	switch {
+	case len(r) <= 4:
+		return strings.Repeat("*", len(r))
+	case len(r) <= 10:
+		return string(r[:1]) + strings.Repeat("*", len(r)-2) + string(r[len(r)-1:])
+	case len(r) > 40:
+		return string(r[:4]) + strings.Repeat("*", 8) + "…" + string(r[len(r)-2:])
+	}
+	return string(r[:4]) + strings.Repeat("*", len(r)-6) + string(r[len(r)-2:])
+}
@@ -0,0 +1,70 @@
+// Package server 负责 HTTP 服务的监听方式：TCP 端口、Unix 套接字、HTTPS（证书热加载）与 HTTP→HTTPS 跳转。
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/config"
+)
+
+// Listen 按配置创建监听：设置了 server.socket 时监听 Unix 套接字，否则监听 TCP 端口
+func Listen(cfg config.ServerConfig) (net.Listener, error) {
+	if cfg.Socket == "" {
+		return net.Listen("tcp", ":"+cfg.Port)
+	}
+
+	// 清理上次未正常退出遗留的套接字文件；同名普通文件视为配置错误
+	if st, err := os.Lstat(cfg.Socket); err == nil {
+		if st.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("server.socket: %s exists and is not a socket", cfg.Socket)
+		}
+		if err := os.Remove(cfg.Socket); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", cfg.Socket)
+	if err != nil {
+		return nil, err
+	}
+	mode, _ := strconv.ParseUint(cfg.SocketMode, 8, 32) // 已在配置校验中检查
+	if err := os.Chmod(cfg.Socket, fs.FileMode(mode)); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// RedirectHandler 将 HTTP 请求跳转到同主机的 HTTPS 端口（443 时省略端口）
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			// 308 保留请求方法与请求体
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
@@ -17,6 +17,7 @@ func main() {
 	email := flag.String("email", "", "邮箱")
 	password := flag.String("password", "", "密码（至少6位）")
 	tokenName := flag.String("token-name", "", "可选：创建一个同名 API Token")
+	admin := flag.Bool("admin", false, "可选：设为管理员")
 	flag.Parse()
 
 	if *username == "" || *email == "" || *password == "" {
@@ -44,6 +45,7 @@ func main() {
 		Email:        *email,
 		PasswordHash: string(hash),
 		IsActive:     true,
+		IsAdmin:      *admin,
 	}
 	if err := models.DB.Create(user).Error; err != nil {
 		log.Fatalf("创建用户失败: %v", err)
@@ -54,6 +56,9 @@ func main() {
 	fmt.Printf("用户 ID: %s\n", userID)
 	fmt.Printf("用户名: %s\n", *username)
 	fmt.Printf("邮箱: %s\n", *email)
+	if *admin {
+		fmt.Println("角色: 管理员")
+	}
 
 	if *tokenName != "" {
 		raw := generateAPIToken()
@@ -0,0 +1,191 @@
+// mockidp 是用于本地联调 OIDC 登录的最小身份提供方，切勿用于生产。
+//
+// 它实现发现文档、JWKS、授权端点（自动同意，不校验密码）与令牌端点（校验 PKCE），
+// 并以启动时生成的 RSA 密钥签发 ID Token。
+//
+//	go run ./tools/mockidp -addr :9000 -email alice@example.com -groups share-admins
+//
+// 然后以下列环境变量启动服务：
+//
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=siyuan-share OIDC_ADMIN_GROUPS=share-admins
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+type authCode struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	email         string
+	expiresAt     time.Time
+}
+
+func main() {
+	addr := flag.String("addr", ":9000", "监听地址")
+	issuer := flag.String("issuer", "", "issuer（默认 http://localhost<addr>）")
+	clientID := flag.String("client-id", "siyuan-share", "允许的 client_id")
+	email := flag.String("email", "alice@example.com", "默认登录邮箱（可在授权请求中用 login_hint 覆盖）")
+	groups := flag.String("groups", "", "逗号分隔的 groups 声明")
+	unverified := flag.Bool("unverified", false, "签发 email_verified=false 的令牌")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://localhost" + *addr
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("生成密钥失败: %v", err)
+	}
+	const kid = "mock-key-1"
+
+	var (
+		mu    sync.Mutex
+		codes = map[string]authCode{}
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                *issuer,
+			"authorization_endpoint":                *issuer + "/authorize",
+			"token_endpoint":                        *issuer + "/token",
+			"jwks_uri":                              *issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("client_id") != *clientID || q.Get("response_type") != "code" {
+			http.Error(w, "invalid client_id or response_type", http.StatusBadRequest)
+			return
+		}
+		if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+			http.Error(w, "PKCE S256 required", http.StatusBadRequest)
+			return
+		}
+		redirect, err := url.Parse(q.Get("redirect_uri"))
+		if err != nil || redirect.Scheme == "" {
+			http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+			return
+		}
+		loginEmail := *email
+		if hint := q.Get("login_hint"); hint != "" {
+			loginEmail = hint
+		}
+		code := randomString(24)
+		mu.Lock()
+		codes[code] = authCode{
+			clientID:      q.Get("client_id"),
+			redirectURI:   q.Get("redirect_uri"),
+			nonce:         q.Get("nonce"),
+			codeChallenge: q.Get("code_challenge"),
+			email:         loginEmail,
+			expiresAt:     time.Now().Add(time.Minute),
+		}
+		mu.Unlock()
+
+		rq := redirect.Query()
+		rq.Set("code", code)
+		rq.Set("state", q.Get("state"))
+		redirect.RawQuery = rq.Encode()
+		log.Printf("authorize: %s -> %s", loginEmail, redirect.String())
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+			return
+		}
+		mu.Lock()
+		ac, ok := codes[r.PostForm.Get("code")]
+		delete(codes, r.PostForm.Get("code"))
+		mu.Unlock()
+		if !ok || time.Now().After(ac.expiresAt) || ac.redirectURI != r.PostForm.Get("redirect_uri") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != ac.codeChallenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+			return
+		}
+
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"iss":                *issuer,
+			"sub":                "mock|" + ac.email,
+			"aud":                ac.clientID,
+			"exp":                now.Add(5 * time.Minute).Unix(),
+			"iat":                now.Unix(),
+			"nonce":              ac.nonce,
+			"email":              ac.email,
+			"email_verified":     !*unverified,
+			"preferred_username": strings.SplitN(ac.email, "@", 2)[0],
+		}
+		if *groups != "" {
+			claims["groups"] = strings.Split(*groups, ",")
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = kid
+		idToken, err := tok.SignedString(key)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": randomString(24),
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     idToken,
+		})
+	})
+
+	fmt.Printf("Mock IdP listening on %s (issuer %s, client_id %s)\n", *addr, *issuer, *clientID)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
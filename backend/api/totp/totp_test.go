@@ -0,0 +1,101 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret RFC 6238 附录 B 的 SHA1 密钥 "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateCodeRFC6238(t *testing.T) {
+	// 附录 B 的 8 位验证码取后 6 位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := GenerateCode(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("GenerateCode(t=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	counter := Counter(now)
+	code := func(offset int64) string {
+		c, err := GenerateCode(rfcSecret, counter+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		code   string
+		skew   int
+		want   int64
+		wantOK bool
+	}{
+		{"current step", code(0), 1, counter, true},
+		{"previous step within skew", code(-1), 1, counter - 1, true},
+		{"next step within skew", code(1), 1, counter + 1, true},
+		{"two steps behind", code(-2), 1, 0, false},
+		{"two steps ahead", code(2), 1, 0, false},
+		{"previous step without skew", code(-1), 0, 0, false},
+		{"spaces are ignored", code(0)[:3] + " " + code(0)[3:], 1, counter, true},
+		{"too short", code(0)[:5], 1, 0, false},
+		{"too long", code(0) + "0", 1, 0, false},
+		{"empty", "", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidSecret(t *testing.T) {
+	if _, ok := Validate("not base32!", "123456", time.Now(), 1); ok {
+		t.Error("Validate accepted a code for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if len(a) != 32 || a == b {
+		t.Errorf("GenerateSecret() = %q, %q, want distinct 32-char secrets", a, b)
+	}
+	if _, err := GenerateCode(strings.ToLower(a), 1); err != nil {
+		t.Errorf("lowercase secret rejected: %v", err)
+	}
+}
+
+func TestKeyURI(t *testing.T) {
+	got := KeyURI("SiYuan Share", "alice@example.com", rfcSecret)
+	want := "otpauth://totp/SiYuan%20Share:alice@example.com?algorithm=SHA1&digits=6&issuer=SiYuan+Share&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("KeyURI() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,333 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ZeroHawkeye/siyuan-share-api/scanner"
+)
+
+// 内置转换器
+func init() {
+	Register(Definition{
+		Name:        "refs",
+		Description: "块引用替换为链接，嵌入块展开为引用内容",
+		Stages:      []Stage{StageView},
+		New:         newRefs,
+	})
+	Register(Definition{
+		Name:        "links",
+		Description: "按前缀改写链接地址，siyuan://blocks/ 链接指向已发布的引用块片段",
+		Stages:      []Stage{StagePublish, StageView},
+		New:         newLinks,
+	})
+	Register(Definition{
+		Name:        "footer",
+		Description: "在文档末尾追加页脚与许可协议",
+		Stages:      []Stage{StagePublish, StageView},
+		New:         newFooter,
+	})
+	Register(Definition{
+		Name:        "title",
+		Description: "标题为空时由内容生成：取第一个非标题行，全部为标题时取第一个标题",
+		Stages:      []Stage{StagePublish},
+		New:         newTitle,
+	})
+	Register(Definition{
+		Name:        "redact",
+		Description: "将匹配检测规则的内容替换为 [REDACTED:<rule>]",
+		Stages:      []Stage{StagePublish, StageView},
+		New:         newRedact,
+	})
+}
+
+// newRefs refs：由调用方提供的 Document.Refs 渲染块引用与嵌入块，不接受选项
+func newRefs(options json.RawMessage) (Transformer, error) {
+	if err := decodeOptions(options, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return TransformerFunc(func(ctx context.Context, doc *Document) error {
+		if doc.Refs == nil {
+			return nil
+		}
+		content, err := doc.Refs(ctx, doc.Content)
+		if err != nil {
+			return err
+		}
+		doc.Content = content
+		return nil
+	}), nil
+}
+
+// linkTargetPattern Markdown 链接与图片的地址 ](url)、自动链接 <scheme://...>
+var linkTargetPattern = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?|<((?:https?|siyuan)://[^>\s]+)>`)
+
+// siyuanBlockLinkPattern 思源块链接 siyuan://blocks/<块ID>
+var siyuanBlockLinkPattern = regexp.MustCompile(`^siyuan://blocks/([0-9]{14}-[0-9a-z]{7})\b`)
+
+const maxLinkRewrites = 50
+
+type linkRewrite struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type linksTransformer struct {
+	Rewrite []linkRewrite `json:"rewrite"` // 按顺序匹配前缀，第一个匹配的生效
+	SiYuan  bool          `json:"siyuan"`  // 查看阶段将 siyuan://blocks/ 链接指向已发布的片段（默认开启）
+}
+
+func newLinks(options json.RawMessage) (Transformer, error) {
+	t := &linksTransformer{SiYuan: true}
+	if err := decodeOptions(options, t); err != nil {
+		return nil, err
+	}
+	if len(t.Rewrite) > maxLinkRewrites {
+		return nil, fmt.Errorf("at most %d rewrite rules", maxLinkRewrites)
+	}
+	for _, r := range t.Rewrite {
+		if r.From == "" {
+			return nil, errors.New("rewrite: from must not be empty")
+		}
+	}
+	return t, nil
+}
+
+func (t *linksTransformer) Transform(ctx context.Context, doc *Document) error {
+	locs := linkTargetPattern.FindAllStringSubmatchIndex(doc.Content, -1)
+	if len(locs) == 0 {
+		return nil
+	}
+
+	// 批量解析思源块链接
+	var blocks map[string]string
+	if t.SiYuan && doc.Resolve != nil {
+		var ids []string
+		seen := map[string]bool{}
+		for _, loc := range locs {
+			start, end := targetOf(loc)
+			if m := siyuanBlockLinkPattern.FindStringSubmatch(doc.Content[start:end]); m != nil && !seen[m[1]] {
+				seen[m[1]] = true
+				ids = append(ids, m[1])
+			}
+		}
+		if len(ids) > 0 {
+			var err error
+			if blocks, err = doc.Resolve(ctx, ids); err != nil {
+				return err
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(len(doc.Content))
+	last := 0
+	for _, loc := range locs {
+		start, end := targetOf(loc)
+		b.WriteString(doc.Content[last:start])
+		b.WriteString(t.rewrite(doc.Content[start:end], blocks))
+		last = end
+	}
+	b.WriteString(doc.Content[last:])
+	doc.Content = b.String()
+	return nil
+}
+
+// targetOf 匹配中链接地址的位置
+func targetOf(loc []int) (start, end int) {
+	if loc[2] >= 0 {
+		return loc[2], loc[3]
+	}
+	return loc[4], loc[5]
+}
+
+func (t *linksTransformer) rewrite(target string, blocks map[string]string) string {
+	if m := siyuanBlockLinkPattern.FindStringSubmatch(target); m != nil {
+		if link, ok := blocks[m[1]]; ok {
+			return link
+		}
+	}
+	for _, r := range t.Rewrite {
+		if strings.HasPrefix(target, r.From) {
+			return r.To + target[len(r.From):]
+		}
+	}
+	return target
+}
+
+// licenses 页脚可用的许可协议（SPDX 标识）
+var licenses = map[string]struct{ name, url string }{
+	"CC-BY-4.0":       {"CC BY 4.0", "https://creativecommons.org/licenses/by/4.0/"},
+	"CC-BY-SA-4.0":    {"CC BY-SA 4.0", "https://creativecommons.org/licenses/by-sa/4.0/"},
+	"CC-BY-ND-4.0":    {"CC BY-ND 4.0", "https://creativecommons.org/licenses/by-nd/4.0/"},
+	"CC-BY-NC-4.0":    {"CC BY-NC 4.0", "https://creativecommons.org/licenses/by-nc/4.0/"},
+	"CC-BY-NC-SA-4.0": {"CC BY-NC-SA 4.0", "https://creativecommons.org/licenses/by-nc-sa/4.0/"},
+	"CC-BY-NC-ND-4.0": {"CC BY-NC-ND 4.0", "https://creativecommons.org/licenses/by-nc-nd/4.0/"},
+	"CC0-1.0":         {"CC0 1.0", "https://creativecommons.org/publicdomain/zero/1.0/"},
+}
+
+const maxFooterText = 2000
+
+type footerTransformer struct {
+	Text     string `json:"text"`     // 页脚文本（Markdown），{title} 与 {url} 替换为标题与页面地址
+	License  string `json:"license"`  // 许可协议的 SPDX 标识，如 CC-BY-4.0
+	Snippets bool   `json:"snippets"` // 是否同时追加到引用块片段（默认只追加到文档分享）
+}
+
+func newFooter(options json.RawMessage) (Transformer, error) {
+	t := &footerTransformer{}
+	if err := decodeOptions(options, t); err != nil {
+		return nil, err
+	}
+	t.Text = strings.TrimSpace(t.Text)
+	if t.Text == "" && t.License == "" {
+		return nil, errors.New("text or license is required")
+	}
+	if len([]rune(t.Text)) > maxFooterText {
+		return nil, fmt.Errorf("text must be at most %d characters", maxFooterText)
+	}
+	if _, ok := licenses[t.License]; t.License != "" && !ok {
+		return nil, fmt.Errorf("unknown license %q", t.License)
+	}
+	return t, nil
+}
+
+func (t *footerTransformer) Transform(_ context.Context, doc *Document) error {
+	if doc.Kind == KindSnippet && !t.Snippets {
+		return nil
+	}
+	var parts []string
+	if t.Text != "" {
+		parts = append(parts, strings.NewReplacer("{title}", doc.Title, "{url}", doc.URL).Replace(t.Text))
+	}
+	if l, ok := licenses[t.License]; ok {
+		parts = append(parts, "本文采用 ["+l.name+"]("+l.url+") 许可协议。")
+	}
+	doc.Content = strings.TrimRight(doc.Content, "\n") + "\n\n---\n\n" + strings.Join(parts, "\n\n") + "\n"
+	return nil
+}
+
+// defaultTitle 内容中没有可用文本时的标题
+const defaultTitle = "引用块"
+
+type titleTransformer struct {
+	MaxLength int `json:"maxLength"` // 标题最多的字符数，超出时截断并追加省略号（默认 50）
+}
+
+// newTitle title：标题为空时（如引用块片段没有显示文本）由内容生成标题
+func newTitle(options json.RawMessage) (Transformer, error) {
+	t := &titleTransformer{MaxLength: 50}
+	if err := decodeOptions(options, t); err != nil {
+		return nil, err
+	}
+	if t.MaxLength < 1 || t.MaxLength > 200 {
+		return nil, errors.New("maxLength must be between 1 and 200")
+	}
+	return t, nil
+}
+
+func (t *titleTransformer) Transform(_ context.Context, doc *Document) error {
+	if strings.TrimSpace(doc.Title) != "" {
+		return nil
+	}
+	// 优先使用第一个非标题行，全部为标题时使用第一个标题的文本
+	var heading string
+	for _, line := range strings.Split(doc.Content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			if heading == "" {
+				heading = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			}
+		default:
+			doc.Title = t.truncate(line)
+			return nil
+		}
+	}
+	if heading == "" {
+		heading = defaultTitle
+	}
+	doc.Title = t.truncate(heading)
+	return nil
+}
+
+// truncate 按字符截断，超出时追加省略号
+func (t *titleTransformer) truncate(s string) string {
+	if r := []rune(s); len(r) > t.MaxLength {
+		return string(r[:t.MaxLength]) + "..."
+	}
+	return s
+}
+
+const maxRedactPatterns = 50
+
+type redactOptions struct {
+	Rules    *[]string `json:"rules"` // 启用的内置检测规则 ID，未设置时为全部内置规则
+	Patterns []struct {
+		Name    string `json:"name"`
+		Pattern string `json:"pattern"`
+	} `json:"patterns"` // 自定义正则，规则 ID 为 custom:<name>
+	Allowlist []string `json:"allowlist"` // 匹配任一正则的内容不替换
+}
+
+type redactTransformer struct {
+	sc *scanner.Scanner
+}
+
+func newRedact(options json.RawMessage) (Transformer, error) {
+	var opts redactOptions
+	if err := decodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	if len(opts.Patterns) > maxRedactPatterns || len(opts.Allowlist) > maxRedactPatterns {
+		return nil, fmt.Errorf("at most %d patterns and %d allowlist entries", maxRedactPatterns, maxRedactPatterns)
+	}
+
+	var rules []scanner.Rule
+	builtin := scanner.BuiltinRules()
+	if opts.Rules == nil {
+		rules = builtin
+	} else {
+		byID := make(map[string]scanner.Rule, len(builtin))
+		for _, r := range builtin {
+			byID[r.ID] = r
+		}
+		for _, id := range *opts.Rules {
+			r, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("unknown built-in rule %q", id)
+			}
+			rules = append(rules, r)
+		}
+	}
+	for _, p := range opts.Patterns {
+		if p.Name == "" || p.Pattern == "" {
+			return nil, errors.New("patterns: name and pattern are required")
+		}
+		r, err := scanner.CustomRule(p.Name, p.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	allow := make([]*regexp.Regexp, 0, len(opts.Allowlist))
+	for _, p := range opts.Allowlist {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("allowlist %q: %w", p, err)
+		}
+		allow = append(allow, re)
+	}
+	return &redactTransformer{sc: scanner.New(rules, allow)}, nil
+}
+
+func (t *redactTransformer) Transform(_ context.Context, doc *Document) error {
+	doc.Title = scanner.Redact(doc.Title, t.sc.Scan(doc.Title))
+	doc.Content = scanner.Redact(doc.Content, t.sc.Scan(doc.Content))
+	return nil
+}
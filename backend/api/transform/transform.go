@@ -0,0 +1,244 @@
+// Package transform 可插拔的内容转换流水线：发布阶段的结果写入数据库，查看阶段的结果进入渲染缓存。
+// 转换器通过 Register 注册，按分享、用户、服务端默认的优先级选择流水线，按列表顺序执行。
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Stage 转换阶段
+type Stage string
+
+const (
+	StagePublish Stage = "publish" // 发布时，转换结果写入数据库
+	StageView    Stage = "view"    // 查看时，转换结果进入渲染缓存，不修改已保存的内容
+)
+
+// 转换对象的类型
+const (
+	KindShare   = "share"   // 文档分享
+	KindSnippet = "snippet" // 引用块片段
+)
+
+// MaxSteps 每个阶段最多的转换器数
+const MaxSteps = 20
+
+// Document 转换对象。转换器直接修改 Title 与 Content
+type Document struct {
+	Stage   Stage
+	Kind    string
+	ID      string // 分享或片段 ID，发布阶段新建时为空
+	BlockID string // 引用块片段的块 ID
+	Title   string
+	Content string
+	URL     string // 查看阶段的页面地址
+
+	// Refs 查看阶段由调用方提供：替换块引用为链接并展开嵌入块
+	Refs func(ctx context.Context, content string) (string, error)
+	// Resolve 查看阶段由调用方提供：批量解析块 ID 的链接地址，无法链接的块不在结果中
+	Resolve func(ctx context.Context, blockIDs []string) (map[string]string, error)
+}
+
+// Transformer 内容转换器
+type Transformer interface {
+	Transform(ctx context.Context, doc *Document) error
+}
+
+// TransformerFunc 以函数实现 Transformer
+type TransformerFunc func(ctx context.Context, doc *Document) error
+
+func (f TransformerFunc) Transform(ctx context.Context, doc *Document) error { return f(ctx, doc) }
+
+// Definition 注册的转换器
+type Definition struct {
+	Name        string
+	Description string
+	Stages      []Stage // 支持的阶段
+	// New 按选项（JSON，未设置时为 nil）创建转换器，选项无效时返回错误
+	New func(options json.RawMessage) (Transformer, error)
+}
+
+// Supports 是否支持该阶段
+func (d Definition) Supports(stage Stage) bool {
+	for _, s := range d.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Definition{}
+)
+
+// Register 注册转换器，名称重复时 panic
+func Register(d Definition) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if d.Name == "" || d.New == nil {
+		panic("transform: Register requires a name and a constructor")
+	}
+	if _, dup := registry[d.Name]; dup {
+		panic("transform: Register called twice for " + d.Name)
+	}
+	registry[d.Name] = d
+}
+
+// Lookup 按名称查找转换器
+func Lookup(name string) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	d, ok := registry[name]
+	return d, ok
+}
+
+// Definitions 全部已注册的转换器，按名称排序
+func Definitions() []Definition {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	defs := make([]Definition, 0, len(registry))
+	for _, d := range registry {
+		defs = append(defs, d)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Step 流水线中的一步
+type Step struct {
+	Name    string          `json:"name"`
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// Pipeline 分享或用户的流水线配置。阶段为 null（未设置）时继承下一级配置，空列表表示该阶段不做转换
+type Pipeline struct {
+	Publish []Step `json:"publish"`
+	View    []Step `json:"view"`
+}
+
+// Steps 该阶段的配置，nil 表示未设置
+func (p *Pipeline) Steps(stage Stage) []Step {
+	if p == nil {
+		return nil
+	}
+	if stage == StagePublish {
+		return p.Publish
+	}
+	return p.View
+}
+
+// Validate 校验两个阶段的配置
+func (p *Pipeline) Validate() error {
+	for _, stage := range []Stage{StagePublish, StageView} {
+		if _, err := Build(stage, p.Steps(stage)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode 解析保存的流水线配置，为空时返回 nil
+func Decode(s string) (*Pipeline, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var p Pipeline
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Encode 序列化流水线配置，nil 为空字符串
+func Encode(p *Pipeline) string {
+	if p == nil {
+		return ""
+	}
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// Select 按优先级选择该阶段的配置：pipelines 中第一个设置了该阶段的配置，都未设置时使用 defaults（转换器名称）
+func Select(stage Stage, defaults []string, pipelines ...*Pipeline) []Step {
+	for _, p := range pipelines {
+		if steps := p.Steps(stage); steps != nil {
+			return steps
+		}
+	}
+	steps := make([]Step, len(defaults))
+	for i, name := range defaults {
+		steps[i] = Step{Name: name}
+	}
+	return steps
+}
+
+// CheckNames 校验转换器名称已注册且支持该阶段（用于服务端默认配置）
+func CheckNames(stage Stage, names []string) error {
+	_, err := Build(stage, Select(stage, names))
+	return err
+}
+
+// Chain 已创建的流水线
+type Chain []link
+
+type link struct {
+	name string
+	t    Transformer
+}
+
+// Build 按配置创建该阶段的流水线
+func Build(stage Stage, steps []Step) (Chain, error) {
+	if len(steps) > MaxSteps {
+		return nil, fmt.Errorf("%s: at most %d transformers", stage, MaxSteps)
+	}
+	chain := make(Chain, 0, len(steps))
+	for i, step := range steps {
+		d, ok := Lookup(step.Name)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d]: unknown transformer %q", stage, i, step.Name)
+		}
+		if !d.Supports(stage) {
+			return nil, fmt.Errorf("%s[%d]: transformer %q does not support the %s stage", stage, i, step.Name, stage)
+		}
+		opts := step.Options
+		if len(bytes.TrimSpace(opts)) == 0 || bytes.Equal(bytes.TrimSpace(opts), []byte("null")) {
+			opts = nil
+		}
+		t, err := d.New(opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d] %s: %w", stage, i, step.Name, err)
+		}
+		chain = append(chain, link{name: step.Name, t: t})
+	}
+	return chain, nil
+}
+
+// Run 按顺序执行，任一转换器出错时停止
+func (c Chain) Run(ctx context.Context, doc *Document) error {
+	for _, l := range c {
+		if err := l.t.Transform(ctx, doc); err != nil {
+			return fmt.Errorf("transformer %s: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+// decodeOptions 解析选项，不允许未知字段；未设置选项时保持 v 的默认值
+func decodeOptions(options json.RawMessage, v any) error {
+	if options == nil {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(options))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
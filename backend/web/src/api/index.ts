@@ -1,8 +1,9 @@
 import axios from 'axios';
+import { basePath } from '../basePath';
 
-// 生产环境使用相对路径，开发环境使用环境变量指定的完整URL
+// 生产环境使用挂载前缀下的相对路径，开发环境使用环境变量指定的完整URL
 const api = axios.create({
-  baseURL: import.meta.env.DEV ? (import.meta.env.VITE_API_URL || 'http://localhost:8080') : '',
+  baseURL: import.meta.env.DEV ? (import.meta.env.VITE_API_URL || 'http://localhost:8080') : basePath,
   timeout: 10000,
 })
 
@@ -4,6 +4,7 @@ export interface ShareData {
   id: string
   docTitle: string
   content: string
+  anchors?: Record<string, string> | null // 同一文档内的块 ID → 页内锚点
   requirePassword: boolean
   expireAt: string
   viewCount: number
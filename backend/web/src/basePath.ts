@@ -0,0 +1,6 @@
+// 服务端挂载前缀（如 /notes），由后端在 index.html 中通过 <meta name="base-path"> 注入；开发环境为空
+export const basePath: string =
+  document.querySelector('meta[name="base-path"]')?.getAttribute('content')?.replace(/\/$/, '') ?? ''
+
+// withBase 为站内路径加上挂载前缀
+export const withBase = (path: string): string => basePath + path
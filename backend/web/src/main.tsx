@@ -4,6 +4,7 @@ import zhCN from "antd/locale/zh_CN";
 import ReactDOM from "react-dom/client";
 import { BrowserRouter } from "react-router-dom";
 import App from "./App.tsx";
+import { basePath } from "./basePath";
 import "./index.css";
 
 // 检测暗黑模式
@@ -19,7 +20,7 @@ ReactDOM.createRoot(document.getElementById("root")!).render(
       },
     }}
   >
-    <BrowserRouter>
+    <BrowserRouter basename={basePath || undefined}>
       <App />
     </BrowserRouter>
   </ConfigProvider>
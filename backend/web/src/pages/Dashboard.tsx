@@ -3,6 +3,7 @@ import { Button, Card, Divider, Form, Input, message, Modal, Space, Table, Tag,
 import { useEffect, useState } from 'react'
 import { useNavigate } from 'react-router-dom'
 import api from '../api'
+import { withBase } from '../basePath'
 
 const { Title, Text, Paragraph } = Typography
 
@@ -177,7 +178,7 @@ function Dashboard() {
         <Card>
           <Space direction="vertical" size="large">
             <Text type="secondary">未登录或会话失效</Text>
-            <Button type="primary" href="/">返回首页</Button>
+            <Button type="primary" href={withBase("/")}>返回首页</Button>
           </Space>
         </Card>
       </div>
@@ -196,7 +197,7 @@ function Dashboard() {
             <Button icon={<ShareAltOutlined />} onClick={() => navigate('/shares')}>
               分享管理
             </Button>
-            <Button icon={<HomeOutlined />} href="/">返回首页</Button>
+            <Button icon={<HomeOutlined />} href={withBase("/")}>返回首页</Button>
           </Space>
         </Space>
       </div>
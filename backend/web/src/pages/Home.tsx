@@ -3,14 +3,13 @@ import { Button, Card, Divider, Form, Input, Space, Tabs, Tag, Typography, messa
 import { useEffect, useState } from 'react'
 import api from '../api'
 import './Home.css'
+import { withBase } from '../basePath'
 
 const { Title, Text, Paragraph } = Typography
 
 interface HealthData {
   status: string
   ts: number
-  userCount: number
-  ginMode: string
   version: string
 }
 
@@ -130,7 +129,7 @@ function Home() {
                       已登录：{sessionUser.username}
                     </Text>
                     <Space size="middle">
-                      <Button type="primary" icon={<DashboardOutlined />} href="/dashboard">
+                      <Button type="primary" icon={<DashboardOutlined />} href={withBase("/dashboard")}>
                         进入仪表盘
                       </Button>
                       <Button icon={<LogoutOutlined />} onClick={handleLogout}>退出登录</Button>
@@ -1,11 +1,12 @@
 import './NotFound.css'
+import { withBase } from '../basePath'
 
 function NotFound() {
   return (
     <div className="not-found">
       <h1>404</h1>
       <p>页面不存在</p>
-      <a href="/">返回首页</a>
+      <a href={withBase("/")}>返回首页</a>
     </div>
   )
 }
@@ -11,6 +11,7 @@ import rehypeSlug from 'rehype-slug'
 import remarkGfm from 'remark-gfm'
 import { getShare, ShareData } from '../api/share'
 import './ShareView.css'
+import { withBase } from '../basePath'
 
 const { Content, Sider } = Layout
 const { Title, Text } = Typography
@@ -207,6 +208,30 @@ function ShareView() {
     })
   }, [share?.content])
 
+  // 定位页内锚点：#block-<块ID> 按发布时的锚点映射查找，其余按元素 id 查找
+  useEffect(() => {
+    if (!share?.content) return
+
+    const scrollToHash = () => {
+      const hash = decodeURIComponent(window.location.hash.slice(1))
+      if (!hash) return
+      let target = document.getElementById(hash)
+      const blockId = hash.startsWith('block-') ? hash.slice('block-'.length) : ''
+      if (!target && blockId && share.anchors?.[blockId]) {
+        target = document.getElementById(share.anchors[blockId])
+      }
+      target?.scrollIntoView({ behavior: 'smooth', block: 'start' })
+    }
+
+    // 等待 Markdown 渲染与标题 id 生成完成
+    const timer = window.setTimeout(scrollToHash, 0)
+    window.addEventListener('hashchange', scrollToHash)
+    return () => {
+      window.clearTimeout(timer)
+      window.removeEventListener('hashchange', scrollToHash)
+    }
+  }, [share?.content, share?.anchors])
+
   const scrollToTop = () => {
     window.scrollTo({ top: 0, behavior: 'smooth' })
   }
@@ -290,7 +315,7 @@ function ShareView() {
             <Button 
               type="primary" 
               icon={<HomeOutlined />}
-              onClick={() => window.location.href = '/'}
+              onClick={() => window.location.href = withBase('/')}
               key="home"
             >
               返回首页
@@ -327,7 +352,7 @@ function ShareView() {
             <Button 
               type="primary" 
               icon={<HomeOutlined />}
-              onClick={() => window.location.href = '/'}
+              onClick={() => window.location.href = withBase('/')}
               key="home"
             >
               返回首页
@@ -11,5 +11,6 @@ export default defineConfig({
     outDir: 'dist',
     emptyOutDir: true,
   },
-  base: '/',
+  // 相对路径构建，后端按部署前缀改写 index.html 中的资源地址
+  base: './',
 })
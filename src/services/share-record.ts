@@ -94,7 +94,6 @@ export class ShareRecordManager {
                     method: "GET",
                     headers: {
                         "Authorization": `Bearer ${config.apiToken}`,
-                        "X-Base-URL": base,
                     },
                     signal: controller.signal,
                 });
@@ -286,7 +285,6 @@ export class ShareRecordManager {
                     method: "GET",
                     headers: {
                         "Authorization": `Bearer ${apiToken}`,
-                        "X-Base-URL": base,
                     },
                     signal: controller.signal,
                 });
@@ -1,6 +1,6 @@
 import { showMessage } from "siyuan";
 import type SharePlugin from "../index";
-import type { AssetUploadRecord, BatchDeleteShareResponse, BlockReference, KramdownResponse, ShareOptions, ShareRecord, ShareResponse, UploadProgressCallback } from "../types";
+import type { AssetUploadRecord, BatchDeleteShareResponse, BlockReference, KramdownResponse, PublishReport, ScanReport, ShareOptions, ShareRecord, ShareResponse, UploadProgressCallback } from "../types";
 import { BlockReferenceResolver } from "../utils/block-reference-resolver";
 import { parseKramdownToMarkdown } from "../utils/kramdown-parser";
 import { S3UploadService } from "./s3-upload";
@@ -68,6 +68,7 @@ export class ShareService {
             docId: options.docId,
             docTitle: options.docTitle,
             content: processedContent,
+            format: "kramdown", // 正文与引用块均为 kramdown，由服务端转换
             requirePassword: options.requirePassword,
             password: options.requirePassword ? options.password ?? "" : "",
             expireDays: options.expireDays,
@@ -81,11 +82,14 @@ export class ShareService {
             const response = await this.callShareAPI(config.serverUrl, config.apiToken, payload);
             
             if (response.code !== 0) {
-                throw new Error(response.msg || this.plugin.i18n.shareErrorUnknown);
+                const detail = this.formatFailedReferences(response.data?.report) + this.formatScanFindings(response.data?.scan);
+                throw new Error((response.msg || this.plugin.i18n.shareErrorUnknown) + detail);
             }
 
             // 5. 保存分享记录到本地
             const shareData = response.data;
+            this.notifyPublishReport(shareData.report);
+            this.notifyScanReport(shareData.scan);
             const record: ShareRecord = {
                 id: shareData.shareId,
                 docId: shareData.docId || options.docId,
@@ -182,16 +186,9 @@ export class ShareService {
                 引用块ID列表: references.map(r => r.blockId),
             });
 
-            // 3. 将 Kramdown 转换为 Markdown
-            const markdown = parseKramdownToMarkdown(kramdownContent);
-            
-            if (!markdown) {
-                console.error("Kramdown 解析结果为空", { docId, kramdownLength: kramdownContent.length });
-                showMessage(this.plugin.i18n.kramdownParseError, 4000, "error");
-                return { content: "", references: [] };
-            }
-
-            return { content: markdown, references };
+            // 3. 直接提交 kramdown（format: "kramdown"），由服务端按 IAL 移除不公开的块后再转换为 Markdown；
+            // 在插件中转换会去掉 IAL，服务端将无法识别 custom-share="hide" 等属性
+            return { content: kramdownContent, references };
         } catch (error) {
             console.error("导出文档时发生异常:", error, { docId });
             
@@ -330,14 +327,21 @@ export class ShareService {
                 headers: {
                     "Content-Type": "application/json",
                     "Authorization": `Bearer ${apiToken}`,
-                    // 明确传递 Base URL（后端也会自动推断，双轨兼容）
-                    "X-Base-URL": base,
                 },
                 body: JSON.stringify(payload),
             });
 
             if (!response.ok) {
                 const errorText = await response.text().catch(() => response.statusText);
+                // 发布失败时服务端返回带发布结果的 JSON，交由调用方展示失败的引用块
+                try {
+                    const body = JSON.parse(errorText);
+                    if (body && typeof body.code === "number" && (body.data?.report || body.data?.scan)) {
+                        return body as ShareResponse;
+                    }
+                } catch {
+                    // 非 JSON 响应
+                }
                 throw new Error(`HTTP ${response.status}: ${errorText}`);
             }
 
@@ -352,6 +356,61 @@ export class ShareService {
         }
     }
 
+    /**
+     * 提示被跳过的引用块（重复、引用自身、内容为空）
+     */
+    private notifyPublishReport(report?: PublishReport): void {
+        if (!report || report.skipped.length === 0) {
+            return;
+        }
+        const lines = report.skipped.map((r) => `${r.blockId}: ${r.reason ?? ""}`);
+        showMessage(
+            `${this.plugin.i18n.shareRefsSkipped || "部分引用块未发布"}（${report.skipped.length}）\n${lines.join("\n")}`,
+            6000,
+            "info"
+        );
+    }
+
+    /**
+     * 提示检测到的敏感信息（warn 模式照常发布，redact 模式已替换为占位符）
+     */
+    private notifyScanReport(scan?: ScanReport | null): void {
+        if (!scan || scan.findings.length === 0) {
+            return;
+        }
+        const title = scan.redacted
+            ? this.plugin.i18n.shareScanRedacted || "已将以下敏感信息替换为占位符后发布"
+            : this.plugin.i18n.shareScanFound || "内容中可能包含敏感信息";
+        showMessage(`${title}（${scan.findings.length}）\n${this.formatFindingLines(scan)}`, 8000, "info");
+    }
+
+    /**
+     * 将拒绝发布的敏感信息格式化为错误信息的附加说明
+     */
+    private formatScanFindings(scan?: ScanReport | null): string {
+        if (!scan || scan.findings.length === 0) {
+            return "";
+        }
+        return `\n${this.plugin.i18n.shareScanBlocked || "检测到敏感信息，未发布"}：\n${this.formatFindingLines(scan)}`;
+    }
+
+    private formatFindingLines(scan: ScanReport): string {
+        return scan.findings
+            .map((f) => `${f.blockId ?? f.source} L${f.line}:${f.column} ${f.name} ${f.masked}`)
+            .join("\n");
+    }
+
+    /**
+     * 将发布失败的引用块格式化为错误信息的附加说明
+     */
+    private formatFailedReferences(report?: PublishReport): string {
+        if (!report || report.failed.length === 0) {
+            return "";
+        }
+        const lines = report.failed.map((r) => `${r.blockId}: ${r.reason ?? ""}`);
+        return `\n${this.plugin.i18n.shareRefsFailed || "以下引用块发布失败，未保存任何更改"}：\n${lines.join("\n")}`;
+    }
+
     /**
      * 删除分享
      */
@@ -34,6 +34,8 @@ export interface BlockReference {
     content: string;
     displayText?: string;
     refCount?: number;
+    /** 嵌套引用：该块内容中引用的块（服务端按 RENDER_MAX_REF_DEPTH 限制层数） */
+    references?: BlockReference[];
 }
 
 /**
@@ -72,9 +74,44 @@ export interface ShareResponse {
         createdAt: string;
         updatedAt: string;
         reused: boolean;
+        report?: PublishReport;
+        scan?: ScanReport | null;
     };
 }
 
+export interface PublishRefResult {
+    blockId: string;
+    shareId?: string;
+    shareUrl?: string;
+    reason?: string;
+}
+
+/** 引用块发布结果（发布为整体事务，失败时不写入任何数据） */
+export interface PublishReport {
+    created: PublishRefResult[];
+    updated: PublishRefResult[];
+    skipped: PublishRefResult[];
+    failed: PublishRefResult[];
+}
+
+/** 敏感信息检测结果，位置按提交的原文计算 */
+export interface ScanFinding {
+    source: "title" | "content" | "reference" | "embed";
+    blockId?: string;
+    rule: string;
+    name: string;
+    line: number;
+    column: number;
+    length: number;
+    masked: string;
+}
+
+export interface ScanReport {
+    mode: "warn" | "block" | "redact";
+    redacted: boolean;
+    findings: ScanFinding[];
+}
+
 export interface ShareListResponse {
     code: number;
     msg: string;
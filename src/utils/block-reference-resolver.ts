@@ -4,7 +4,7 @@
  */
 
 import type { BlockReference, KramdownResponse } from "../types";
-import { extractBlockReferences, parseKramdownToMarkdown } from "./kramdown-parser";
+import { extractBlockReferences } from "./kramdown-parser";
 
 /**
  * 块引用解析器选项
@@ -63,9 +63,8 @@ export class BlockReferenceResolver {
                 return null;
             }
 
-            // 将 Kramdown 转换为 Markdown
-            const markdown = parseKramdownToMarkdown(result.data.kramdown);
-            return markdown;
+            // 保留 kramdown（含 IAL），由服务端移除不公开的块后再转换
+            return result.data.kramdown;
         } catch (error) {
             console.error("获取块内容异常:", error, blockId);
             return null;
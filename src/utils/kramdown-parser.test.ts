@@ -42,12 +42,12 @@ const testCases = [
     },
     {
         name: "块引用转换 - 带显示文本",
-        input: `这是一个引用 ((20210101-abc1234 "参考资料")) 的示例。`,
+        input: `这是一个引用 ((20210101120000-abc1234 "参考资料")) 的示例。`,
         expected: `这是一个引用 [参考资料] 的示例。`
     },
     {
         name: "块引用转换 - 无显示文本",
-        input: `查看 ((20210101-abc1234)) 了解详情。`,
+        input: `查看 ((20210101120000-abc1234)) 了解详情。`,
         expected: `查看 [引用] 了解详情。`
     },
     {